@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// AuthConfig - настройки аутентификации. Меняются только после перезапуска.
+type AuthConfig struct {
+	JWTSecret      string `json:"jwt_secret"`      // секрет HMAC для JWT; пусто - токены не принимаются
+	InternalSecret string `json:"internal_secret"` // секрет подписи заголовков X-Auth-*, общий с внутренними сервисами
+	Database       string `json:"database"`        // файл SQLite с API ключами и пользователями
+	AdminKey       string `json:"admin_key"`       // ключ администратора для выпуска первых ключей
+	AllowAnonymous bool   `json:"allow_anonymous"` // чтение новостей без учетных данных
+}
+
+func (c *AuthConfig) validate() error {
+	var err error
+	if c.JWTSecret != "" && len(c.JWTSecret) < auth.MinSecretSize {
+		err = fmt.Errorf("auth.jwt_secret: must be at least %d bytes", auth.MinSecretSize)
+	}
+	return errors.Join(err, auth.CheckSecret("auth.internal_secret", c.InternalSecret), config.Required("auth.database", c.Database))
+}
+
+// newAuthenticator создает проверку учетных данных по настройкам
+func newAuthenticator(c AuthConfig, keys, sessions auth.KeyLookup) (*auth.Authenticator, error) {
+	authn := &auth.Authenticator{Keys: keys, Sessions: sessions, AdminKey: c.AdminKey}
+	if c.AllowAnonymous {
+		authn.Anonymous = auth.RoleReader
+	}
+	if c.JWTSecret != "" {
+		signer, err := auth.NewSigner(c.JWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		authn.Tokens = signer
+	}
+	return authn, nil
+}
+
+// APIKey - выпущенный API ключ. Сам ключ не хранится, только его хэш.
+type APIKey struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Role      auth.Role `json:"role"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt string    `json:"created_at"`
+	RevokedAt *string   `json:"revoked_at"`
+}
+
+// keyStore - API ключи в SQLite
+type keyStore struct {
+	db *sql.DB
+}
+
+func openKeyStore(path string) (*keyStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			role TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			prefix TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			revoked_at DATETIME
+		)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &keyStore{db: db}, nil
+}
+
+// Create выпускает ключ; возвращается описание ключа и сам ключ
+func (s *keyStore) Create(ctx context.Context, name string, role auth.Role) (APIKey, string, error) {
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	prefix := key[:len(auth.KeyPrefix)+6]
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_keys (name, role, key_hash, prefix, created_at) VALUES (?, ?, ?, ?, datetime('now'))`,
+		name, role, auth.HashKey(key), prefix)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	id, _ := res.LastInsertId()
+	k, err := s.get(ctx, id)
+	return k, key, err
+}
+
+// List возвращает все ключи, включая отозванные
+func (s *keyStore) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, role, prefix, created_at, revoked_at FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.Role, &k.Prefix, &k.CreatedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke отзывает ключ. Для неизвестного или уже отозванного ключа - sql.ErrNoRows.
+func (s *keyStore) Revoke(ctx context.Context, id int64) (APIKey, error) {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = datetime('now') WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return APIKey{}, sql.ErrNoRows
+	}
+	return s.get(ctx, id)
+}
+
+func (s *keyStore) get(ctx context.Context, id int64) (APIKey, error) {
+	var k APIKey
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, name, role, prefix, created_at, revoked_at FROM api_keys WHERE id = ?`, id,
+	).Scan(&k.ID, &k.Name, &k.Role, &k.Prefix, &k.CreatedAt, &k.RevokedAt)
+	return k, err
+}
+
+// LookupKey реализует auth.KeyLookup: отозванные ключи не принимаются
+func (s *keyStore) LookupKey(ctx context.Context, hash string) (auth.Principal, error) {
+	var (
+		id   int64
+		name string
+		role auth.Role
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, name, role FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`, hash,
+	).Scan(&id, &name, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{Subject: "key:" + strconv.FormatInt(id, 10), Role: role, Method: auth.MethodAPIKey, Name: name}, nil
+}
+
+// GET /admin/keys — список ключей, POST /admin/keys {"name": "...", "role": "..."} — новый ключ.
+// Ключ возвращается в ответе один раз.
+func handleKeys(w http.ResponseWriter, r *http.Request) {
+	if keys == nil {
+		apierror.Write(w, r, apierror.NotFound("key management is not enabled"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := keys.List(r.Context())
+		if err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+		writeJSON(w, list)
+	case http.MethodPost:
+		var req struct {
+			Name string    `json:"name"`
+			Role auth.Role `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON())
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" || !req.Role.Valid() {
+			apierror.Write(w, r, apierror.BadRequest("name and valid role required"))
+			return
+		}
+		k, key, err := keys.Create(r.Context(), req.Name, req.Role)
+		if err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+		writeJSON(w, map[string]any{"api_key": k, "key": key})
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
+
+// DELETE /admin/keys/{id} — отзыв ключа
+func handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	if keys == nil {
+		apierror.Write(w, r, apierror.NotFound("key management is not enabled"))
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		apierror.Write(w, r, apierror.BadRequest("invalid key id"))
+		return
+	}
+	k, err := keys.Revoke(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("key not found"))
+		return
+	}
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	writeJSON(w, k)
+}
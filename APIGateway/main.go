@@ -3,31 +3,155 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
-	"time"
+
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/compress"
+	"news/pkg/config"
+	"news/pkg/fields"
+	"news/pkg/health"
+	"news/pkg/httpcache"
+	"news/pkg/metrics"
+	"news/pkg/middleware"
+	"news/pkg/openapi"
+	"news/pkg/server"
+	"news/pkg/tracing"
 )
 
+// Config - настройки шлюза: config.json, переменные GATEWAY_* и флаги
 type Config struct {
-	NewsService     string `json:"news_service"`
-	CommentsService string `json:"comments_service"`
-	Port            string `json:"port"`
+	Addr            string         `json:"addr"` // адрес HTTP сервера
+	NewsService     string         `json:"news_service"`
+	CommentsService string         `json:"comments_service"`
+	CensorService   string         `json:"censor_service"`
+	PopularWindow   int            `json:"popular_window"` // окно для /news/popular, часы
+	PopularLimit    int            `json:"popular_limit"`
+	Auth            AuthConfig     `json:"auth"`
+	Tracing         tracing.Config `json:"tracing"`
+}
+
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.Addr("addr", c.Addr),
+		config.URL("news_service", c.NewsService),
+		config.URL("comments_service", c.CommentsService),
+		config.URL("censor_service", c.CensorService),
+		config.Positive("popular_window", c.PopularWindow),
+		config.Positive("popular_limit", c.PopularLimit),
+		c.Auth.validate(),
+	)
+}
+
+// defaultConfig - настройки для запуска всех сервисов на одной машине
+func defaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		NewsService:     "http://localhost:80",
+		CommentsService: "http://localhost:8081",
+		CensorService:   "http://localhost:8082",
+		PopularWindow:   24,
+		PopularLimit:    10,
+		Auth:            AuthConfig{Database: "./gateway.db", AllowAnonymous: true},
+		Tracing:         tracing.ConfigFromEnv(),
+	}
+}
+
+var (
+	cfg   = defaultConfig()
+	cfgMu sync.RWMutex // адреса сервисов меняются при перезагрузке настроек
+
+	// reloader перечитывает настройки; nil, пока не создан в main
+	reloader *config.Reloader
+
+	// authn проверяет учетные данные клиентов; до настройки в main
+	// доступно только чтение без учетных данных
+	authn = &auth.Authenticator{Anonymous: auth.RoleReader}
+	// keys - хранилище API ключей; nil, пока не открыто в main
+	keys *keyStore
+	// users - учетные записи и сессии; nil, пока не открыты в main
+	users *userStore
+)
+
+// conf возвращает текущие настройки
+func conf() Config {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg
+}
+
+// applyConfig применяет перезагруженные настройки. Адрес сервера,
+// трассировка и аутентификация меняются только после перезапуска.
+func applyConfig(next *Config) error {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	if next.Addr != cfg.Addr || next.Tracing != cfg.Tracing || next.Auth != cfg.Auth {
+		log.Printf("Config reload: addr, tracing and auth changes require restart")
+	}
+	next.Addr, next.Tracing, next.Auth = cfg.Addr, cfg.Tracing, cfg.Auth
+	cfg = *next
+	return nil
 }
 
-var cfg Config
+// upstream - клиент для запросов к внутренним сервисам; main создает его
+// заново с секретом из настроек
+var upstream = newUpstream("")
 
-func init() {
-	cfg = Config{
-		NewsService:     "http://localhost:80",   // Сервис новостей
-		CommentsService: "http://localhost:8081", // Сервис комментариев
-		Port:            ":8080",
+// newUpstream создает клиента внутренних сервисов: длительность запросов
+// попадает в метрики с именем сервиса, ID запроса передается в X-Request-ID,
+// а клиент - в заголовках X-Auth-*, подписанных секретом secret
+func newUpstream(secret string) *http.Client {
+	return &http.Client{
+		Transport: metrics.Transport(tracing.Transport(middleware.Transport(auth.Transport(http.DefaultTransport, secret))), upstreamName),
 	}
 }
 
+// upstreamGet выполняет GET к внутреннему сервису в контексте входящего запроса:
+// отмена запроса клиентом прерывает вызов, а трасса продолжается в сервисе
+func upstreamGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return upstream.Do(req)
+}
+
+// upstreamPost выполняет POST к внутреннему сервису в контексте входящего запроса
+func upstreamPost(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return upstream.Do(req)
+}
+
+// upstreamName определяет сервис по адресу запроса
+func upstreamName(r *http.Request) string {
+	c := conf()
+	for name, base := range map[string]string{
+		"news":     c.NewsService,
+		"comments": c.CommentsService,
+		"censor":   c.CensorService,
+	} {
+		if u, err := url.Parse(base); err == nil && u.Host == r.URL.Host {
+			return name
+		}
+	}
+	return "other"
+}
+
 // Структуры ответа
 
 type NewsResponse struct {
@@ -45,8 +169,19 @@ type NewsShortDetailed struct {
 	ID      int    `json:"id"`
 	Title   string `json:"title"`
 	Content string `json:"content"`
+	Summary string `json:"summary"`
 	PubTime int64  `json:"pub_time"`
 	Link    string `json:"link"`
+	Lang    string `json:"lang"`
+	Tags    []Tag  `json:"tags"`
+}
+
+// Поля новости, которые можно запросить параметром fields
+var newsFields = fields.NewSet("id", "title", "content", "summary", "pub_time", "link", "lang", "tags")
+
+type Tag struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
 }
 
 type NewsFullDetailed struct {
@@ -59,80 +194,98 @@ type NewsFullDetailed struct {
 
 type Comment struct {
 	ID        int    `json:"id"`
-	Author    string `json:"author"`
+	Author    string `json:"author"`    // отображаемое имя из профиля пользователя
+	AuthorID  *int64 `json:"author_id"` // nil у комментариев, добавленных без учетной записи
 	Text      string `json:"text"`
 	CreatedAt string `json:"created_at"`
 }
 
-type serviceResult struct {
-	data interface{}
-	err  error
+// Новость с активностью обсуждения
+type PopularNews struct {
+	NewsShortDetailed
+	Comments      int    `json:"comments"`
+	LastCommentAt string `json:"last_comment_at"`
 }
 
-// Middleware
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+type newsStats struct {
+	NewsID        int    `json:"news_id"`
+	Comments      int    `json:"comments"`
+	LastCommentAt string `json:"last_comment_at"`
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
-
-func loggerMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := r.URL.Query().Get("request_id")
-		if len(reqID) < 6 {
-			reqID = fmt.Sprintf("req-%d", time.Now().UnixNano())
-		}
-		ctx := context.WithValue(r.Context(), "request_id", reqID)
-		w.Header().Set("X-Request-ID", reqID)
-
-		start := time.Now()
-		rw := &responseWriter{w, http.StatusOK}
-		next.ServeHTTP(rw, r.WithContext(ctx))
-
-		log.Printf("[%s] GATEWAY | %s %s | STATUS: %d | ID: %s | DUR: %v",
-			time.Now().Format("15:04:05"), r.Method, r.URL.Path, reqID, rw.statusCode, time.Since(start))
-	})
+type serviceResult struct {
+	data interface{}
+	err  *apierror.Error
 }
 
 // Обработчики
 
 // GET /news
 func handleNews(w http.ResponseWriter, r *http.Request) {
-	reqID, _ := r.Context().Value("request_id").(string)
 	s := r.URL.Query().Get("s")
+	tag := r.URL.Query().Get("tag")
+	lang := r.URL.Query().Get("lang")
 	page := r.URL.Query().Get("page")
 	if page == "" {
 		page = "1"
 	}
+	// Списку на клиенте обычно достаточно id,title,pub_time,link
+	selected, err := newsFields.Parse(r.URL.Query().Get("fields"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
 
 	// Формируем URL к микросервису новостей
-	targetURL := fmt.Sprintf("%s/news?s=%s&page=%s&request_id=%s",
-		cfg.NewsService, url.QueryEscape(s), page, reqID)
+	targetURL := fmt.Sprintf("%s/news?s=%s&tag=%s&lang=%s&page=%s&fields=%s",
+		conf().NewsService, url.QueryEscape(s), url.QueryEscape(tag), url.QueryEscape(lang), page,
+		url.QueryEscape(strings.Join(selected, ",")))
 
-	resp, err := http.Get(targetURL)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, targetURL, nil)
 	if err != nil {
-		http.Error(w, "news service unreachable", http.StatusServiceUnavailable)
+		apierror.Internal(w, r, err)
+		return
+	}
+	// Сервис новостей сам проверяет ETag и не выполняет выборку, если страница не изменилась
+	httpcache.ForwardConditional(req, r)
+
+	resp, err := upstream.Do(req)
+	if err != nil {
+		apierror.Write(w, r, upstreamError("news service", err))
 		return
 	}
 	defer resp.Body.Close()
+	httpcache.CopyValidators(w.Header(), resp.Header)
+	if resp.StatusCode == http.StatusNotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
 
 	var data NewsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		http.Error(w, "error decoding news", http.StatusInternalServerError)
+		apierror.Write(w, r, badUpstream("news service"))
+		return
+	}
+	if selected == nil {
+		writeJSON(w, data)
 		return
 	}
-	writeJSON(w, data)
+	news, err := fields.ProjectAll(data.News, selected)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]any{"news": news, "pagination": data.Pagination})
 }
 
 func getNewsDetail(w http.ResponseWriter, r *http.Request) {
-	reqID, _ := r.Context().Value("request_id").(string)
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		http.Error(w, "id is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("id is required"))
 		return
 	}
 
@@ -143,30 +296,45 @@ func getNewsDetail(w http.ResponseWriter, r *http.Request) {
 	// Запрос к новостям
 	go func() {
 		defer wg.Done()
-		url := fmt.Sprintf("%s/news/%s?request_id=%s", cfg.NewsService, id, reqID)
-		resp, err := http.Get(url)
+		url := fmt.Sprintf("%s/news/%s", conf().NewsService, url.PathEscape(id))
+		resp, err := upstreamGet(r.Context(), url)
 		if err != nil {
-			resChan <- serviceResult{err: err}
+			resChan <- serviceResult{err: upstreamError("news service", err)}
 			return
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			resChan <- serviceResult{err: apierror.FromResponse(resp)}
+			return
+		}
 		var n NewsFullDetailed
-		json.NewDecoder(resp.Body).Decode(&n)
+		if err := json.NewDecoder(resp.Body).Decode(&n); err != nil {
+			resChan <- serviceResult{err: badUpstream("news service")}
+			return
+		}
 		resChan <- serviceResult{data: n}
 	}()
 
 	// Запрос к комментариям
 	go func() {
 		defer wg.Done()
-		url := fmt.Sprintf("%s/comments?news_id=%s&request_id=%s", cfg.CommentsService, id, reqID)
-		resp, err := http.Get(url)
+		url := fmt.Sprintf("%s/comments?news_id=%s", conf().CommentsService, url.QueryEscape(id))
+		resp, err := upstreamGet(r.Context(), url)
 		if err != nil {
-			resChan <- serviceResult{err: err}
+			resChan <- serviceResult{err: upstreamError("comments service", err)}
 			return
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			resChan <- serviceResult{err: apierror.FromResponse(resp)}
+			return
+		}
 		var c []Comment
-		json.NewDecoder(resp.Body).Decode(&c)
+		if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+			resChan <- serviceResult{err: badUpstream("comments service")}
+			return
+		}
+		resolveAuthors(r.Context(), c)
 		resChan <- serviceResult{data: c}
 	}()
 
@@ -176,7 +344,7 @@ func getNewsDetail(w http.ResponseWriter, r *http.Request) {
 	var result NewsFullDetailed
 	for res := range resChan {
 		if res.err != nil {
-			http.Error(w, "internal service error", http.StatusInternalServerError)
+			apierror.Write(w, r, res.err)
 			return
 		}
 		switch v := res.data.(type) {
@@ -186,58 +354,353 @@ func getNewsDetail(w http.ResponseWriter, r *http.Request) {
 			result.Comments = v
 		}
 	}
-	writeJSON(w, result)
+	// Ответ собирается из двух сервисов, поэтому ETag считается по итоговому телу
+	httpcache.WriteJSON(w, r, result, "public, max-age=0, must-revalidate")
+}
+
+// GET /news/popular?window=24&limit=10 — новости с самым активным обсуждением за окно (часы)
+func handlePopular(w http.ResponseWriter, r *http.Request) {
+	c := conf()
+	window := c.PopularWindow
+	if v, err := strconv.Atoi(r.URL.Query().Get("window")); err == nil && v > 0 {
+		window = v
+	}
+	limit := c.PopularLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+
+	// Статистика комментариев
+	statsURL := fmt.Sprintf("%s/comments/stats?hours=%d&limit=%d",
+		c.CommentsService, window, limit)
+	resp, err := upstreamGet(r.Context(), statsURL)
+	if err != nil {
+		apierror.Write(w, r, upstreamError("comments service", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
+	var stats []newsStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		apierror.Write(w, r, badUpstream("comments service"))
+		return
+	}
+
+	// Новости запрашиваются параллельно; удаленные новости пропускаются
+	result := make([]*PopularNews, len(stats))
+	var wg sync.WaitGroup
+	for i, st := range stats {
+		wg.Add(1)
+		go func(i int, st newsStats) {
+			defer wg.Done()
+			url := fmt.Sprintf("%s/news/%d", c.NewsService, st.NewsID)
+			resp, err := upstreamGet(r.Context(), url)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+			var n NewsShortDetailed
+			if err := json.NewDecoder(resp.Body).Decode(&n); err != nil {
+				return
+			}
+			result[i] = &PopularNews{NewsShortDetailed: n, Comments: st.Comments, LastCommentAt: st.LastCommentAt}
+		}(i, st)
+	}
+	wg.Wait()
+
+	popular := []PopularNews{}
+	for _, p := range result {
+		if p != nil {
+			popular = append(popular, *p)
+		}
+	}
+	httpcache.WriteJSON(w, r, map[string]any{"window": window, "news": popular}, "public, max-age=60")
+}
+
+// GET /trending — растущие темы из сервиса новостей
+func handleTrending(w http.ResponseWriter, r *http.Request) {
+	resp, err := upstreamGet(r.Context(), conf().NewsService+"/trending?"+r.URL.RawQuery)
+	if err != nil {
+		apierror.Write(w, r, upstreamError("news service", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// GET /news/stream — поток новых постов (SSE) из сервиса новостей.
+// Каждый полученный фрагмент сразу передается клиенту.
+func handleStream(w http.ResponseWriter, r *http.Request) {
+	// Запрос отменяется вместе с клиентским соединением
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, conf().NewsService+"/news/stream?"+r.URL.RawQuery, nil)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+
+	resp, err := upstream.Do(req)
+	if err != nil {
+		apierror.Write(w, r, upstreamError("news service", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
+
+	for _, h := range []string{"Content-Type", "Cache-Control", "X-Accel-Buffering"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if ferr := rc.Flush(); ferr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// GET /comments/ws?news_id=1 — WebSocket с событиями комментариев новости.
+// ReverseProxy сам переключает протокол и передает кадры в обе стороны.
+func handleCommentsWS(w http.ResponseWriter, r *http.Request) {
+	target, err := url.Parse(conf().CommentsService)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	proxy := &httputil.ReverseProxy{
+		Transport: upstream.Transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			apierror.Write(w, r, upstreamError("comments service", err))
+		},
+	}
+	proxy.ServeHTTP(w, r)
 }
 
 func handleAddComment(w http.ResponseWriter, r *http.Request) {
-	reqID, _ := r.Context().Value("request_id").(string)
+	// Автор - клиент запроса: пользователь сессии или владелец ключа (токена).
+	// Сервис комментариев получает его в X-Auth-Subject и X-Auth-Name.
 	// Читаем тело комментария
 	var commentData map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&commentData); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidJSON())
 		return
 	}
+	// Имя автора больше не задается клиентом
+	delete(commentData, "author")
+	delete(commentData, "author_id")
 
 	// Превращаем обратно в байты для отправки в сервисы
 	bodyBytes, _ := json.Marshal(commentData)
 
 	// СИНХРОННЫЙ ЗАПРОС К ЦЕНЗОРУ
-	censorURL := fmt.Sprintf("http://localhost:8082/censor?request_id=%s", reqID)
+	censorURL := conf().CensorService + "/censor"
 	// Создаем новый запрос, так как r.Body уже прочитан
-	censorResp, err := http.Post(censorURL, "application/json", strings.NewReader(string(bodyBytes)))
-
-	if err != nil || censorResp.StatusCode != http.StatusOK {
-		http.Error(w, "Comment failed censorship", http.StatusBadRequest)
+	censorResp, err := upstreamPost(r.Context(), censorURL, "application/json", strings.NewReader(string(bodyBytes)))
+	if err != nil {
+		apierror.Write(w, r, upstreamError("censor service", err))
+		return
+	}
+	defer censorResp.Body.Close()
+	if censorResp.StatusCode != http.StatusOK {
+		// Код content_rejected и статус цензора передаются клиенту без изменений
+		apierror.Forward(w, r, censorResp)
 		return
 	}
 
 	// ЕСЛИ ЦЕНЗОР ОДОБРИЛ (200 OK) — ОТПРАВЛЯЕМ В СЕРВИС КОММЕНТАРИЕВ
-	commentsURL := fmt.Sprintf("%s/comments?request_id=%s", cfg.CommentsService, reqID)
-	resp, err := http.Post(commentsURL, "application/json", strings.NewReader(string(bodyBytes)))
+	commentsURL := conf().CommentsService + "/comments"
+	resp, err := upstreamPost(r.Context(), commentsURL, "application/json", strings.NewReader(string(bodyBytes)))
 	if err != nil {
-		http.Error(w, "Comments service unreachable", http.StatusServiceUnavailable)
+		apierror.Write(w, r, upstreamError("comments service", err))
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
 
 	var res map[string]interface{}
 	json.NewDecoder(resp.Body).Decode(&res)
 	writeJSON(w, res)
 }
 
+// PUT /comments/{id} {"text": "..."}, DELETE /comments/{id} — модерация комментариев.
+// Запрос передается сервису комментариев вместе с модератором в X-Auth-*.
+func handleModerateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method,
+		conf().CommentsService+"/comments/"+url.PathEscape(r.PathValue("id")), r.Body)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := upstream.Do(req)
+	if err != nil {
+		apierror.Write(w, r, upstreamError("comments service", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apierror.Forward(w, r, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.Copy(w, resp.Body)
+}
+
+// upstreamError - сервис недоступен или не ответил за время, отведенное запросу
+func upstreamError(service string, err error) *apierror.Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apierror.Timeout()
+	}
+	return apierror.Unavailable(service)
+}
+
+// badUpstream - ответ сервиса не удалось разобрать
+func badUpstream(service string) *apierror.Error {
+	return apierror.New(http.StatusBadGateway, apierror.CodeUpstream, "invalid response from "+service)
+}
+
 func writeJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
 
-func main() {
+// GET /readyz — шлюз готов, если внутренние сервисы отвечают
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	upstreamChecker("/healthz").Handler().ServeHTTP(w, r)
+}
+
+// GET /status — готовность каждого сервиса вместе с проверками его зависимостей
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	upstreamChecker("/readyz").StatusHandler().ServeHTTP(w, r)
+}
+
+// GET|POST /admin/reload — результат загрузки настроек и перезагрузка
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if reloader == nil {
+		apierror.Write(w, r, apierror.NotFound("config reload is not enabled"))
+		return
+	}
+	reloader.Handler().ServeHTTP(w, r)
+}
+
+// upstreamChecker проверяет внутренние сервисы запросом к path
+func upstreamChecker(path string) *health.Checker {
+	c := conf()
+	return health.New().
+		AddRemote("news", upstream, c.NewsService+path).
+		AddRemote("comments", upstream, c.CommentsService+path).
+		AddRemote("censor", upstream, c.CensorService+path)
+}
+
+// newHandler регистрирует маршруты и проверку запросов по спецификации OpenAPI
+func newHandler(opts openapi.Options) http.Handler {
+	spec := openapi.MustLoad(openapi.Gateway)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/news", handleNews)
 	mux.HandleFunc("/news/detail", getNewsDetail)
+	mux.HandleFunc("/news/popular", handlePopular)
+	mux.HandleFunc("/news/stream", handleStream)
+	mux.HandleFunc("/trending", handleTrending)
 	mux.HandleFunc("/comment/add", handleAddComment)
+	mux.HandleFunc("/comments/ws", handleCommentsWS)
+	mux.HandleFunc("/comments/{id}", handleModerateComment)
+	mux.Handle("/openapi.json", spec.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.HandleFunc("/readyz", handleReady)
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/admin/reload", handleReload)
+	mux.HandleFunc("/users/register", handleRegister)
+	mux.HandleFunc("/users/login", handleLogin)
+	mux.HandleFunc("/users/logout", handleLogout)
+	mux.HandleFunc("/users/me", handleProfile)
+	mux.HandleFunc("/admin/keys", handleKeys)
+	mux.HandleFunc("/admin/keys/{id}", handleRevokeKey)
+	var handler http.Handler = spec.Middleware(opts)(mux)
+	// Минимальная роль маршрута задана в спецификации (x-role)
+	handler = authn.Middleware(func(r *http.Request) auth.Role { return auth.Role(spec.Role(r)) })(handler)
+	handler = metrics.Middleware(spec.Route)(handler)
+	handler = middleware.Timeout(spec.Timeout(middleware.DefaultTimeout))(handler)
+	handler = middleware.Stack(slog.Default())(handler)
+	return tracing.Middleware(spec.Route)(handler)
+}
 
-	wrappedMux := loggerMiddleware(mux)
+func main() {
+	slog.SetDefault(middleware.NewLogger("gateway"))
 
-	fmt.Println("API Gateway запущен на http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", wrappedMux))
+	opts := config.Options{Name: "gateway", EnvPrefix: "GATEWAY", File: "config.json", Args: os.Args[1:]}
+	config.MustLoad(&cfg, opts)
+	addr := cfg.Addr
+
+	shutdownTracing, err := tracing.Setup("gateway", cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	keys, err = openKeyStore(cfg.Auth.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer keys.db.Close()
+	if users, err = newUserStore(keys.db); err != nil {
+		log.Fatal(err)
+	}
+	if authn, err = newAuthenticator(cfg.Auth, keys, users); err != nil {
+		log.Fatal(err)
+	}
+	upstream = newUpstream(cfg.Auth.InternalSecret)
+
+	// Адреса сервисов и параметры /news/popular меняются без перезапуска
+	reloader = config.NewReloader(opts, defaultConfig, applyConfig)
+	go reloader.Run(context.Background())
+
+	wrappedMux := compress.Middleware(newHandler(openapi.DefaultOptions()))
+
+	fmt.Println("API Gateway запущен на", addr)
+	if err := server.Run(server.New(addr, wrappedMux)); err != nil {
+		log.Fatal(err)
+	}
 }
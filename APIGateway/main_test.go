@@ -0,0 +1,578 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/config"
+	"news/pkg/health"
+	"news/pkg/httpcache"
+	"news/pkg/openapi"
+	"news/pkg/tracing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// upstreams запускает поддельные сервисы новостей и комментариев
+func upstreams(t *testing.T) {
+	news := http.NewServeMux()
+	news.HandleFunc("/news", func(w http.ResponseWriter, r *http.Request) {
+		if httpcache.Check(w, r, `"v1"`, "public, max-age=0, must-revalidate") {
+			return
+		}
+		writeJSON(w, map[string]any{
+			"news": []map[string]any{{"id": 1, "title": "Заголовок", "content": "Текст", "summary": "", "pub_time": 1714564800,
+				"link": "https://example.com/1", "source": "https://example.com/rss", "lang": "ru", "tags": []any{}}},
+			"pagination": map[string]int{"total_pages": 1, "current_page": 1, "items_per_page": 15},
+		})
+	})
+	news.HandleFunc("/news/1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"id": 1, "title": "Заголовок", "content": "Текст", "pub_time": 1714564800,
+			"link": "https://example.com/1", "tags": nil})
+	})
+	news.HandleFunc("/news/2", func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.NotFound("post not found"))
+	})
+	news.Handle("/readyz", health.New().Add("postgres", func(context.Context) error { return nil }).Handler())
+	newsSrv := httptest.NewServer(news)
+	t.Cleanup(newsSrv.Close)
+
+	comments := http.NewServeMux()
+	comments.HandleFunc("/comments", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]any{{"id": 5, "news_id": 1, "parent_id": nil, "author": "alice", "text": "Отлично",
+			"created_at": "2024-05-01T12:00:00Z"}})
+	})
+	comments.HandleFunc("/comments/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]any{
+			{"news_id": 1, "comments": 3, "last_comment_at": "2024-05-01 12:00:00"},
+			{"news_id": 2, "comments": 1, "last_comment_at": "2024-05-01 11:00:00"},
+		})
+	})
+	comments.Handle("/healthz", health.Live())
+	comments.Handle("/readyz", health.New().Add("sqlite", func(context.Context) error {
+		return errors.New("database is locked")
+	}).Handler())
+	commentsSrv := httptest.NewServer(comments)
+	t.Cleanup(commentsSrv.Close)
+
+	old, oldAuthn, oldUpstream := cfg, authn, upstream
+	cfg.NewsService, cfg.CommentsService = newsSrv.URL, commentsSrv.URL
+	authn = &auth.Authenticator{Anonymous: auth.RoleReader, AdminKey: testAdminKey}
+	upstream = newUpstream(testInternalSecret)
+	t.Cleanup(func() { cfg, authn, upstream = old, oldAuthn, oldUpstream })
+}
+
+// testAdminKey - статический ключ администратора в тестах
+const testAdminKey = "test-admin-key"
+
+// testInternalSecret - секрет подписи заголовков X-Auth-* в тестах
+const testInternalSecret = "internal-secret-of-at-least-32-bytes"
+
+func get(t *testing.T, srv *httptest.Server, path string, v any) int {
+	return getWithKey(t, srv, path, "", v)
+}
+
+// getWithKey выполняет GET с API ключом; пустой ключ - анонимный запрос
+func getWithKey(t *testing.T, srv *httptest.Server, path, key string, v any) int {
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	require.NoError(t, err)
+	if key != "" {
+		req.Header.Set(auth.HeaderAPIKey, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, v), string(body))
+	return resp.StatusCode
+}
+
+func TestGatewayResponsesMatchSpec(t *testing.T) {
+	upstreams(t)
+	// В тестовом режиме ответы, не соответствующие спецификации, превращаются в 500
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	var news NewsResponse
+	require.Equal(t, http.StatusOK, get(t, srv, "/news?page=1", &news))
+	require.Len(t, news.News, 1)
+
+	var detail NewsFullDetailed
+	require.Equal(t, http.StatusOK, get(t, srv, "/news/detail?id=1", &detail))
+	require.Len(t, detail.Comments, 1)
+
+	var popular struct {
+		News []PopularNews `json:"news"`
+	}
+	require.Equal(t, http.StatusOK, get(t, srv, "/news/popular", &popular))
+	require.Len(t, popular.News, 1) // новость 2 удалена
+	require.Equal(t, 3, popular.News[0].Comments)
+}
+
+func TestGatewayPopular(t *testing.T) {
+	upstreams(t)
+	var statsQuery string
+	comments := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statsQuery = r.URL.RawQuery
+		writeJSON(w, []map[string]any{
+			{"news_id": 3, "comments": 7, "last_comment_at": "2024-05-01 13:00:00"},
+			{"news_id": 2, "comments": 5, "last_comment_at": "2024-05-01 12:30:00"},
+			{"news_id": 1, "comments": 3, "last_comment_at": "2024-05-01 12:00:00"},
+			{"news_id": 4, "comments": 1, "last_comment_at": "2024-05-01 11:00:00"},
+		})
+	}))
+	defer comments.Close()
+	news := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/news/1", "/news/3":
+			id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/news/"))
+			writeJSON(w, map[string]any{"id": id, "title": "Новость " + r.URL.Path, "pub_time": 1714564800})
+		case "/news/4":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			apierror.Write(w, r, apierror.NotFound("post not found"))
+		}
+	}))
+	defer news.Close()
+	cfg.CommentsService, cfg.NewsService = comments.URL, news.URL
+	cfg.PopularWindow, cfg.PopularLimit = 24, 10
+
+	srv := httptest.NewServer(newHandler(openapi.Options{}))
+	defer srv.Close()
+
+	var popular struct {
+		Window int           `json:"window"`
+		News   []PopularNews `json:"news"`
+	}
+	require.Equal(t, http.StatusOK, get(t, srv, "/news/popular", &popular))
+	require.Equal(t, "hours=24&limit=10", statsQuery)
+	require.Equal(t, 24, popular.Window)
+
+	// Удаленная новость 2 и недоступная 4 пропускаются, порядок статистики сохраняется
+	require.Len(t, popular.News, 2)
+	require.Equal(t, 3, popular.News[0].ID)
+	require.Equal(t, "Новость /news/3", popular.News[0].Title)
+	require.Equal(t, 7, popular.News[0].Comments)
+	require.Equal(t, "2024-05-01 13:00:00", popular.News[0].LastCommentAt)
+	require.Equal(t, "Новость /news/1", popular.News[1].Title)
+	require.Equal(t, 3, popular.News[1].Comments)
+
+	require.Equal(t, http.StatusOK, get(t, srv, "/news/popular?window=6&limit=2", &popular))
+	require.Equal(t, "hours=6&limit=2", statsQuery)
+	require.Equal(t, 6, popular.Window)
+}
+
+func TestGatewayErrors(t *testing.T) {
+	upstreams(t)
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	var e struct{ Error apierror.Error }
+
+	// Код ошибки сервиса новостей сохраняется
+	require.Equal(t, http.StatusNotFound, get(t, srv, "/news/detail?id=2", &e))
+	require.Equal(t, apierror.CodeNotFound, e.Error.Code)
+
+	require.Equal(t, http.StatusBadRequest, get(t, srv, "/news?page=abc", &e))
+	require.Equal(t, apierror.CodeValidation, e.Error.Code)
+
+	require.Equal(t, http.StatusBadRequest, get(t, srv, "/news/detail", &e))
+	require.Equal(t, []any{"query.id: is required"}, e.Error.Details)
+
+	cfg.NewsService = "http://127.0.0.1:1"
+	require.Equal(t, http.StatusServiceUnavailable, get(t, srv, "/news", &e))
+	require.Equal(t, apierror.CodeUnavailable, e.Error.Code)
+}
+
+func TestGatewayConditionalRequests(t *testing.T) {
+	upstreams(t)
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	do := func(path, etag string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Валидаторы сервиса новостей передаются клиенту и обратно
+	resp := do("/news", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+	require.Equal(t, "public, max-age=0, must-revalidate", resp.Header.Get("Cache-Control"))
+
+	resp = do("/news", `"v1"`)
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+
+	// Составной ответ получает ETag шлюза
+	resp = do("/news/detail?id=1", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	resp = do("/news/detail?id=1", etag)
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestGatewayNewsFields(t *testing.T) {
+	upstreams(t)
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	var page struct {
+		News       []map[string]any `json:"news"`
+		Pagination Pagination       `json:"pagination"`
+	}
+	require.Equal(t, http.StatusOK, get(t, srv, "/news?fields=id,title,pub_time,link", &page))
+	require.Len(t, page.News, 1)
+	require.Equal(t, map[string]any{"id": 1.0, "title": "Заголовок", "pub_time": 1714564800.0,
+		"link": "https://example.com/1"}, page.News[0])
+	require.Equal(t, 1, page.Pagination.CurrentPage)
+
+	var e struct{ Error apierror.Error }
+	require.Equal(t, http.StatusBadRequest, get(t, srv, "/news?fields=id,source", &e))
+	require.Equal(t, apierror.CodeBadRequest, e.Error.Code)
+}
+
+func TestGatewayMetrics(t *testing.T) {
+	upstreams(t)
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	var n NewsFullDetailed
+	require.Equal(t, http.StatusOK, get(t, srv, "/news/detail?id=1", &n))
+
+	// Метрики доступны только администраторам
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.HeaderAPIKey, testAdminKey)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `http_requests_total{method="GET",route="/news/detail",status="200"}`)
+	require.Contains(t, string(body), `gateway_upstream_request_duration_seconds_count{method="GET",service="comments",status="200"}`)
+	require.Contains(t, string(body), `gateway_upstream_request_duration_seconds_count{method="GET",service="news",status="200"}`)
+}
+
+func TestGatewayStatus(t *testing.T) {
+	upstreams(t)
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	var live health.Report
+	require.Equal(t, http.StatusOK, get(t, srv, "/healthz", &live))
+	require.Equal(t, health.StatusOK, live.Status)
+
+	// У поддельного сервиса новостей нет /healthz, а цензор не запущен
+	var ready health.Report
+	require.Equal(t, http.StatusServiceUnavailable, get(t, srv, "/readyz", &ready))
+	require.Equal(t, health.StatusFail, ready.Status)
+	require.Equal(t, health.StatusOK, ready.Checks[1].Status)
+
+	// Состояние сервисов видно только администратору
+	var e struct{ Error apierror.Error }
+	require.Equal(t, http.StatusUnauthorized, get(t, srv, "/status", &e))
+	require.Equal(t, apierror.CodeUnauthorized, e.Error.Code)
+
+	var status health.Report
+	require.Equal(t, http.StatusOK, getWithKey(t, srv, "/status", testAdminKey, &status))
+	require.Equal(t, health.StatusFail, status.Status)
+	require.Len(t, status.Checks, 3)
+
+	news := status.Checks[0]
+	require.Equal(t, "news", news.Name)
+	require.Equal(t, health.StatusOK, news.Status)
+	require.Equal(t, "postgres", news.Checks[0].Name)
+
+	comments := status.Checks[1]
+	require.Equal(t, health.StatusFail, comments.Status)
+	require.Equal(t, "database is locked", comments.Checks[0].Error)
+
+	require.Equal(t, "censor", status.Checks[2].Name)
+	require.Equal(t, health.StatusFail, status.Checks[2].Status)
+}
+
+func TestGatewayPropagatesTrace(t *testing.T) {
+	_, err := tracing.Setup("gateway", tracing.Config{})
+	require.NoError(t, err)
+	upstreams(t)
+
+	traceparent := make(chan string, 1)
+	news := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		writeJSON(w, map[string]any{"window": 24, "baseline": 168, "trends": []any{}})
+	}))
+	defer news.Close()
+	cfg.NewsService = news.URL
+
+	srv := httptest.NewServer(newHandler(openapi.Options{}))
+	defer srv.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929b0e0e4736"
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/trending", nil)
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Contains(t, <-traceparent, traceID)
+}
+
+func TestGatewayReload(t *testing.T) {
+	upstreams(t)
+	news := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"window": 48, "baseline": 168, "trends": []any{}})
+	}))
+	defer news.Close()
+
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	write := func(newsService string) {
+		data, err := json.Marshal(map[string]any{"news_service": newsService, "comments_service": cfg.CommentsService,
+			"auth": map[string]any{"internal_secret": testInternalSecret}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+	reloader = config.NewReloader(config.Options{Args: []string{"-config", path}}, defaultConfig, applyConfig)
+	t.Cleanup(func() { reloader = nil })
+
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+	post := func() (int, config.ReloadStatus) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/admin/reload", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+testAdminKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var st config.ReloadStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&st))
+		return resp.StatusCode, st
+	}
+
+	// Новый адрес сервиса новостей применяется без перезапуска
+	write(news.URL)
+	code, st := post()
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, config.TriggerAPI, st.Trigger)
+	var trends map[string]any
+	require.Equal(t, http.StatusOK, get(t, srv, "/trending", &trends))
+	require.Equal(t, float64(48), trends["window"])
+
+	// Некорректный адрес отклоняется, шлюз продолжает работать со старым
+	write("localhost:80")
+	code, st = post()
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Contains(t, st.Error, "news_service")
+	require.Equal(t, news.URL, conf().NewsService)
+
+	var last config.ReloadStatus
+	require.Equal(t, http.StatusUnprocessableEntity, getWithKey(t, srv, "/admin/reload", testAdminKey, &last))
+	require.Equal(t, "failed", last.Status)
+}
+
+func TestGatewayAuth(t *testing.T) {
+	upstreams(t)
+	// Сервис комментариев получает модератора в доверенных заголовках
+	moderator := make(chan http.Header, 1)
+	comments := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moderator <- r.Header.Clone()
+		writeJSON(w, map[string]any{"status": "ok", "id": 5})
+	}))
+	defer comments.Close()
+	cfg.CommentsService = comments.URL
+
+	store, err := openKeyStore(filepath.Join(t.TempDir(), "gateway.db"))
+	require.NoError(t, err)
+	defer store.db.Close()
+	keys = store
+	t.Cleanup(func() { keys = nil })
+	authn, err = newAuthenticator(AuthConfig{AdminKey: testAdminKey, AllowAnonymous: true}, store, nil)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+	do := func(method, path, key, body string, v any) int {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		// Клиент не может выдать себя за администратора
+		req.Header.Set(auth.HeaderRole, "admin")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		return resp.StatusCode
+	}
+
+	var e struct{ Error apierror.Error }
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodDelete, "/comments/5", "", "", &e))
+
+	// Администратор выпускает ключ модератора
+	var created struct {
+		APIKey APIKey `json:"api_key"`
+		Key    string `json:"key"`
+	}
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/admin/keys", testAdminKey, `{"name": "mod", "role": "moderator"}`, &created))
+	require.Equal(t, auth.RoleModerator, created.APIKey.Role)
+	require.True(t, strings.HasPrefix(created.Key, created.APIKey.Prefix))
+
+	// Модератору недоступно управление ключами, но доступна модерация
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, "/admin/keys", created.Key, "", &e))
+	require.Equal(t, apierror.CodeForbidden, e.Error.Code)
+
+	var res map[string]any
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/comments/5", created.Key, "", &res))
+	h := <-moderator
+	require.Equal(t, "moderator", h.Get(auth.HeaderRole))
+	require.Equal(t, fmt.Sprintf("key:%d", created.APIKey.ID), h.Get(auth.HeaderSubject))
+	require.Empty(t, h.Get("Authorization"))
+
+	// Отозванный ключ больше не принимается
+	var revoked APIKey
+	path := fmt.Sprintf("/admin/keys/%d", created.APIKey.ID)
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, path, testAdminKey, "", &revoked))
+	require.NotNil(t, revoked.RevokedAt)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, path, testAdminKey, "", &e))
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodDelete, "/comments/5", created.Key, "", &e))
+
+	var list []APIKey
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/keys", testAdminKey, "", &list))
+	require.Len(t, list, 1)
+}
+
+func TestGatewayUsers(t *testing.T) {
+	upstreams(t)
+	passwordCost = bcrypt.MinCost
+	t.Cleanup(func() { passwordCost = bcrypt.DefaultCost })
+	store, err := openKeyStore(filepath.Join(t.TempDir(), "gateway.db"))
+	require.NoError(t, err)
+	defer store.db.Close()
+	users, err = newUserStore(store.db)
+	require.NoError(t, err)
+	t.Cleanup(func() { users = nil })
+	authn, err = newAuthenticator(AuthConfig{AllowAnonymous: true}, store, users)
+	require.NoError(t, err)
+
+	// Шлюз передает автора в подписанных заголовках; при чтении имя пользователя берется из профиля
+	var authorID, authorName string
+	censor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer censor.Close()
+	comments := httptest.NewServer(auth.Trusted(testInternalSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.NotContains(t, body, "author")
+			p, _ := auth.FromContext(r.Context())
+			authorID, authorName = p.Subject, p.Name
+			writeJSON(w, map[string]any{"status": "ok", "id": 1})
+			return
+		}
+		id, _ := auth.UserID(authorID)
+		writeJSON(w, []map[string]any{{"id": 1, "news_id": 1, "parent_id": nil, "author": authorName, "author_id": id,
+			"text": "Отлично", "created_at": "2024-05-01T12:00:00Z"}})
+	})))
+	defer comments.Close()
+	cfg.CommentsService, cfg.CensorService = comments.URL, censor.URL
+
+	srv := httptest.NewServer(newHandler(openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+	do := func(method, path, token, body string, v any) int {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		return resp.StatusCode
+	}
+
+	var u User
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/users/register", "",
+		`{"login": "alice", "password": "correct horse", "display_name": "Алиса"}`, &u))
+	require.Equal(t, auth.RoleCommenter, u.Role)
+
+	var e struct{ Error apierror.Error }
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/users/register", "",
+		`{"login": "alice", "password": "another one", "display_name": "Не Алиса"}`, &e))
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/register", "",
+		`{"login": "A", "password": "short", "display_name": "Алиса"}`, &e))
+	require.Equal(t, apierror.CodeValidation, e.Error.Code)
+	// Логин и пустое после обрезки имя проверяет обработчик
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/users/register", "",
+		`{"login": "A", "password": "long enough", "display_name": " "}`, &e))
+	require.Len(t, e.Error.Details, 2)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/users/login", "",
+		`{"login": "alice", "password": "wrong password"}`, &e))
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/users/login", "",
+		`{"login": "bob", "password": "correct horse"}`, &e))
+
+	var session struct {
+		Token string `json:"token"`
+		User  User   `json:"user"`
+	}
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/users/login", "",
+		`{"login": "alice", "password": "correct horse"}`, &session))
+	require.Equal(t, u.ID, session.User.ID)
+
+	// Анонимный клиент не может комментировать, а указанное клиентом имя не сохраняется
+	comment := `{"news_id": 1, "author": "admin", "text": "Отлично"}`
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/comment/add", "", comment, &e))
+	var res map[string]any
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/comment/add", session.Token, comment, &res))
+	require.Equal(t, auth.UserSubject(u.ID), authorID)
+	require.Equal(t, "Алиса", authorName)
+
+	// Клиент с ключом роли commenter комментирует под названием ключа
+	_, key, err := store.Create(context.Background(), "Бот новостей", auth.RoleCommenter)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/comment/add", key, comment, &res))
+	require.Equal(t, "Бот новостей", authorName)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/comment/add", session.Token, comment, &res))
+
+	// Новое отображаемое имя сразу видно в комментариях
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/users/me", session.Token, `{"display_name": "Алиса К."}`, &u))
+	var detail NewsFullDetailed
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/news/detail?id=1", "", "", &detail))
+	require.Equal(t, "Алиса К.", detail.Comments[0].Author)
+	require.Equal(t, u.ID, *detail.Comments[0].AuthorID)
+
+	// После выхода токен не принимается
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/users/logout", session.Token, "", &res))
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/users/me", session.Token, "", &e))
+}
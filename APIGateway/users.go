@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"news/pkg/apierror"
+	"news/pkg/auth"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionTTL - срок действия сессии после входа
+const sessionTTL = 30 * 24 * time.Hour
+
+// validLogin - допустимый логин: строчные латинские буквы, цифры, _ . -
+var validLogin = regexp.MustCompile(`^[a-z0-9_.-]{3,32}$`)
+
+var errLoginTaken = errors.New("login already taken")
+
+// passwordCost - сложность bcrypt; тесты снижают ее до минимальной
+var passwordCost = bcrypt.DefaultCost
+
+// User - учетная запись пользователя. Пароль хранится только в виде хэша bcrypt.
+type User struct {
+	ID          int64     `json:"id"`
+	Login       string    `json:"login"`
+	DisplayName string    `json:"display_name"`
+	Role        auth.Role `json:"role"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+// userStore - пользователи и их сессии в SQLite, в той же базе, что и API ключи
+type userStore struct {
+	db *sql.DB
+
+	// dummyHash сравнивается с паролем при неизвестном логине, чтобы время
+	// ответа не выдавало, существует ли пользователь
+	dummyHash []byte
+}
+
+func newUserStore(db *sql.DB) (*userStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			login TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			display_name TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			expires_at DATETIME NOT NULL
+		)`)
+	if err != nil {
+		return nil, err
+	}
+	dummy, err := bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	if err != nil {
+		return nil, err
+	}
+	return &userStore{db: db, dummyHash: dummy}, nil
+}
+
+// Register создает пользователя с ролью commenter
+func (s *userStore) Register(ctx context.Context, login, password, displayName string) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return User{}, err
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO users (login, password_hash, display_name, role, created_at) VALUES (?, ?, ?, ?, datetime('now'))`,
+		login, hash, displayName, auth.RoleCommenter)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return User{}, errLoginTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+	id, _ := res.LastInsertId()
+	return s.Get(ctx, id)
+}
+
+// Login проверяет пароль и открывает сессию. При неверном логине или пароле
+// возвращается auth.ErrInvalidCredentials.
+func (s *userStore) Login(ctx context.Context, login, password string) (token string, expires time.Time, u User, err error) {
+	var hash []byte
+	err = s.db.QueryRowContext(ctx, `SELECT id, password_hash FROM users WHERE login = ?`, login).Scan(&u.ID, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return "", time.Time{}, User{}, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", time.Time{}, User{}, err
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return "", time.Time{}, User{}, auth.ErrInvalidCredentials
+	}
+
+	if token, err = auth.GenerateSession(); err != nil {
+		return "", time.Time{}, User{}, err
+	}
+	// Время хранится в формате datetime() SQLite, чтобы сравнивать его с datetime('now')
+	expires = time.Now().UTC().Add(sessionTTL).Truncate(time.Second)
+	_, err = s.db.ExecContext(ctx, `INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		auth.HashKey(token), u.ID, expires.Format(time.DateTime))
+	if err != nil {
+		return "", time.Time{}, User{}, err
+	}
+	// Заодно удаляются истекшие сессии
+	s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= datetime('now')`)
+
+	u, err = s.Get(ctx, u.ID)
+	return token, expires, u, err
+}
+
+// Logout завершает сессию с токеном token
+func (s *userStore) Logout(ctx context.Context, token string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, auth.HashKey(token))
+	return err
+}
+
+// LookupKey реализует auth.KeyLookup для токенов сессий
+func (s *userStore) LookupKey(ctx context.Context, hash string) (auth.Principal, error) {
+	var (
+		id   int64
+		name string
+		role auth.Role
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT u.id, u.display_name, u.role FROM sessions s JOIN users u ON u.id = s.user_id
+		 WHERE s.token_hash = ? AND s.expires_at > datetime('now')`, hash,
+	).Scan(&id, &name, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{Subject: auth.UserSubject(id), Role: role, Method: auth.MethodSession, Name: name}, nil
+}
+
+// Get возвращает пользователя по ID
+func (s *userStore) Get(ctx context.Context, id int64) (User, error) {
+	var u User
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, login, display_name, role, created_at FROM users WHERE id = ?`, id,
+	).Scan(&u.ID, &u.Login, &u.DisplayName, &u.Role, &u.CreatedAt)
+	return u, err
+}
+
+// SetDisplayName меняет отображаемое имя; комментарии показывают новое имя сразу
+func (s *userStore) SetDisplayName(ctx context.Context, id int64, name string) (User, error) {
+	if _, err := s.db.ExecContext(ctx, `UPDATE users SET display_name = ? WHERE id = ?`, name, id); err != nil {
+		return User{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// DisplayNames возвращает отображаемые имена пользователей по ID
+func (s *userStore) DisplayNames(ctx context.Context, ids []int64) (map[int64]string, error) {
+	names := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, display_name FROM users WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+// resolveAuthors подставляет в комментарии отображаемые имена пользователей.
+// Комментарии без учетной записи сохраняют имя, указанное при добавлении.
+func resolveAuthors(ctx context.Context, comments []Comment) {
+	if users == nil {
+		return
+	}
+	var ids []int64
+	for _, c := range comments {
+		if c.AuthorID != nil {
+			ids = append(ids, *c.AuthorID)
+		}
+	}
+	names, err := users.DisplayNames(ctx, ids)
+	if err != nil {
+		log.Printf("Resolve comment authors: %v", err)
+		return
+	}
+	for i, c := range comments {
+		if c.AuthorID != nil {
+			comments[i].Author = names[*c.AuthorID]
+		}
+	}
+}
+
+// currentUser возвращает ID пользователя, от имени которого выполнен запрос
+func currentUser(r *http.Request) (int64, bool) {
+	p, _ := auth.FromContext(r.Context())
+	return auth.UserID(p.Subject)
+}
+
+// validDisplayName проверяет отображаемое имя, уже очищенное от пробелов по краям
+func validDisplayName(name string) bool {
+	n := utf8.RuneCountInString(name)
+	return n >= 1 && n <= 100
+}
+
+// POST /users/register {"login": "...", "password": "...", "display_name": "..."}
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	if users == nil {
+		apierror.Write(w, r, apierror.NotFound("user accounts are not enabled"))
+		return
+	}
+	var req struct {
+		Login       string `json:"login"`
+		Password    string `json:"password"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON())
+		return
+	}
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	var problems []string
+	if !validLogin.MatchString(req.Login) {
+		problems = append(problems, "login: 3-32 characters a-z, 0-9, _ . -")
+	}
+	// bcrypt учитывает только первые 72 байта пароля
+	if len(req.Password) < 8 || len(req.Password) > 72 {
+		problems = append(problems, "password: 8-72 bytes")
+	}
+	if !validDisplayName(req.DisplayName) {
+		problems = append(problems, "display_name: 1-100 characters")
+	}
+	if problems != nil {
+		apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation, "invalid registration").WithDetails(problems))
+		return
+	}
+
+	u, err := users.Register(r.Context(), req.Login, req.Password, req.DisplayName)
+	if errors.Is(err, errLoginTaken) {
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	writeJSON(w, u)
+}
+
+// POST /users/login {"login": "...", "password": "..."} — токен сессии для Authorization: Bearer
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	if users == nil {
+		apierror.Write(w, r, apierror.NotFound("user accounts are not enabled"))
+		return
+	}
+	var req struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidJSON())
+		return
+	}
+	token, expires, u, err := users.Login(r.Context(), req.Login, req.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		apierror.Write(w, r, apierror.Unauthorized("invalid login or password"))
+		return
+	}
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]any{"token": token, "expires_at": expires, "user": u})
+}
+
+// POST /users/logout — завершение сессии, с которой выполнен запрос
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	p, _ := auth.FromContext(r.Context())
+	if users == nil || p.Method != auth.MethodSession {
+		apierror.Write(w, r, apierror.BadRequest("request is not authenticated with a session"))
+		return
+	}
+	token, _ := auth.Credential(r)
+	if err := users.Logout(r.Context(), token); err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// GET /users/me — профиль, PUT /users/me {"display_name": "..."} — смена отображаемого имени
+func handleProfile(w http.ResponseWriter, r *http.Request) {
+	id, ok := currentUser(r)
+	if users == nil || !ok {
+		apierror.Write(w, r, apierror.Forbidden("user account required"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		u, err := users.Get(r.Context(), id)
+		if err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+		writeJSON(w, u)
+	case http.MethodPut:
+		var req struct {
+			DisplayName string `json:"display_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON())
+			return
+		}
+		name := strings.TrimSpace(req.DisplayName)
+		if !validDisplayName(name) {
+			apierror.Write(w, r, apierror.BadRequest("display_name: 1-100 characters"))
+			return
+		}
+		u, err := users.SetDisplayName(r.Context(), id, name)
+		if err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+		writeJSON(w, u)
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	}
+}
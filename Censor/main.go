@@ -1,28 +1,82 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/config"
+	"news/pkg/health"
+	"news/pkg/metrics"
+	"news/pkg/middleware"
+	"news/pkg/openapi"
+	"news/pkg/server"
+	"news/pkg/tracing"
 )
 
-// Middleware для логов и ID
-func loggerMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := r.URL.Query().Get("request_id")
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("[%s] CENSOR | %s %s | ID: %s | DUR: %v",
-			time.Now().Format("15:04:05"), r.Method, r.URL.Path, reqID, time.Since(start))
-	})
+// Config - настройки сервиса цензуры
+type Config struct {
+	Addr           string         `json:"addr"`            // адрес HTTP сервера
+	Words          []string       `json:"words"`           // запрещенные слова, без учета регистра
+	InternalSecret string         `json:"internal_secret"` // секрет подписи заголовков шлюза, обычно CENSOR_INTERNAL_SECRET
+	Tracing        tracing.Config `json:"tracing"`
 }
 
-func handleCensor(w http.ResponseWriter, r *http.Request) {
+func (c *Config) Validate() error {
+	var words error
+	if len(c.Words) == 0 {
+		words = errors.New("words: at least one word is required")
+	}
+	return errors.Join(config.Addr("addr", c.Addr), words, auth.CheckSecret("internal_secret", c.InternalSecret))
+}
+
+func defaultConfig() Config {
+	return Config{
+		Addr:    ":8082",
+		Words:   []string{"qwerty", "йцукен", "zxvbnm"},
+		Tracing: tracing.ConfigFromEnv(),
+	}
+}
+
+// Censor отклоняет тексты, содержащие одно из запрещенных слов. Словарь
+// заменяется целиком, поэтому запрос всегда проверяется по одной его версии.
+type Censor struct {
+	words atomic.Pointer[[]string] // в нижнем регистре
+}
+
+// NewCensor создает цензор со словарем words
+func NewCensor(words []string) *Censor {
+	c := &Censor{}
+	c.SetWords(words)
+	return c
+}
+
+// SetWords заменяет словарь
+func (c *Censor) SetWords(words []string) {
+	lower := make([]string, len(words))
+	for i, w := range words {
+		lower[i] = strings.ToLower(w)
+	}
+	c.words.Store(&lower)
+}
+
+func (c *Censor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handleCensor(w, r, *c.words.Load())
+}
+
+func handleCensor(w http.ResponseWriter, r *http.Request, badWords []string) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed())
 		return
 	}
 
@@ -31,26 +85,65 @@ func handleCensor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidJSON())
 		return
 	}
 
 	// Наша логика цензуры
-	badWords := []string{"qwerty", "йцукен", "zxvbnm"}
 	for _, word := range badWords {
 		if strings.Contains(strings.ToLower(body.Text), word) {
-			http.Error(w, "Inappropriate content", http.StatusBadRequest) // 400 ошибка
+			metrics.CensorVerdicts.WithLabelValues("rejected").Inc()
+			apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeRejected, "inappropriate content")) // 400 ошибка
 			return
 		}
 	}
 
+	metrics.CensorVerdicts.WithLabelValues("accepted").Inc()
 	w.WriteHeader(http.StatusOK) // 200 OK
 }
 
 func main() {
+	slog.SetDefault(middleware.NewLogger("censor"))
+
+	// Настройки: censor.json, переменные CENSOR_* и флаги
+	cfg := defaultConfig()
+	opts := config.Options{Name: "censor", EnvPrefix: "CENSOR", File: "censor.json", Args: os.Args[1:]}
+	config.MustLoad(&cfg, opts)
+
+	shutdownTracing, err := tracing.Setup("censor", cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	spec := openapi.MustLoad(openapi.Censor)
 	mux := http.NewServeMux()
-	mux.HandleFunc("/censor", handleCensor)
+	censor := NewCensor(cfg.Words)
+	// Словарь можно менять без перезапуска: SIGHUP, правка файла или POST /admin/reload
+	reloader := config.NewReloader(opts, defaultConfig, func(c *Config) error {
+		censor.SetWords(c.Words)
+		return nil
+	})
+	go reloader.Run(context.Background())
 
-	fmt.Println("Censor Service запущен на :8082")
-	log.Fatal(http.ListenAndServe(":8082", loggerMiddleware(mux)))
+	mux.Handle("/censor", censor)
+	mux.Handle("/admin/reload", auth.Require(auth.RoleAdmin)(reloader.Handler()))
+	mux.Handle("/openapi.json", spec.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+	// Цензор не зависит от других сервисов, поэтому готов, пока жив
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.New().Handler())
+
+	// Клиента определяет шлюз и передает в подписанных заголовках X-Auth-*;
+	// секрет меняется только после перезапуска
+	var handler http.Handler = spec.Middleware(openapi.DefaultOptions())(auth.Trusted(cfg.InternalSecret)(mux))
+	handler = metrics.Middleware(spec.Route)(handler)
+	handler = middleware.Timeout(spec.Timeout(2 * time.Second))(handler)
+	handler = middleware.Stack(slog.Default())(handler)
+	handler = tracing.Middleware(spec.Route)(handler)
+
+	fmt.Println("Censor Service запущен на", cfg.Addr)
+	if err := server.Run(server.New(cfg.Addr, handler)); err != nil {
+		log.Fatal(err)
+	}
 }
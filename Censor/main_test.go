@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func censorText(c *Censor, text string) int {
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/censor", strings.NewReader(`{"text": "`+text+`"}`)))
+	return rec.Code
+}
+
+func TestCensor(t *testing.T) {
+	c := NewCensor([]string{"QWERTY"})
+	require.Equal(t, http.StatusOK, censorText(c, "обычный текст"))
+	require.Equal(t, http.StatusBadRequest, censorText(c, "текст с qwerty"))
+
+	c.SetWords([]string{"йцукен"})
+	require.Equal(t, http.StatusOK, censorText(c, "текст с qwerty"))
+	require.Equal(t, http.StatusBadRequest, censorText(c, "ЙЦУКЕН"))
+}
+
+func TestCensorReloadDuringRequests(t *testing.T) {
+	// Оба словаря запрещают "общее", поэтому ответ не зависит от версии словаря
+	first, second := []string{"общее", "первое"}, []string{"второе", "общее"}
+	c := NewCensor(first)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				c.SetWords(second)
+			} else {
+				c.SetWords(first)
+			}
+		}
+	}()
+
+	var requests sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			for j := 0; j < 200; j++ {
+				if code := censorText(c, "это общее слово"); code != http.StatusBadRequest {
+					t.Errorf("ожидали 400, получили %d", code)
+					return
+				}
+				if code := censorText(c, "чистый текст"); code != http.StatusOK {
+					t.Errorf("ожидали 200, получили %d", code)
+					return
+				}
+			}
+		}()
+	}
+	requests.Wait()
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"news/pkg/apierror"
+
+	"github.com/gorilla/websocket"
+)
+
+// Типы событий, отправляемых подписчикам
+const (
+	EventCreated = "created"
+	EventEdited  = "edited"
+	EventDeleted = "deleted"
+)
+
+// CommentEvent - изменение комментария новости
+type CommentEvent struct {
+	Type    string  `json:"type"`
+	Comment Comment `json:"comment"`
+}
+
+const (
+	writeWait  = 10 * time.Second // время на отправку одного сообщения
+	pongWait   = 60 * time.Second // клиент должен ответить на ping за это время
+	pingPeriod = pongWait * 9 / 10
+	sendBuffer = 32 // очередь сообщений клиента; при переполнении клиент отключается
+)
+
+// client - подключение по WebSocket к обсуждению одной новости
+type client struct {
+	newsID int
+	conn   *websocket.Conn
+	send   chan []byte
+	slow   bool // отключен из-за переполнения очереди
+}
+
+// Hub рассылает события комментариев подписчикам новости
+type Hub struct {
+	mu      sync.Mutex
+	clients map[int]map[*client]bool // news_id -> подключения
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: make(map[int]map[*client]bool)}
+}
+
+func (h *Hub) register(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c.newsID] == nil {
+		h.clients[c.newsID] = make(map[*client]bool)
+	}
+	h.clients[c.newsID][c] = true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(c)
+}
+
+func (h *Hub) remove(c *client) {
+	subs := h.clients[c.newsID]
+	if !subs[c] {
+		return
+	}
+	delete(subs, c)
+	if len(subs) == 0 {
+		delete(h.clients, c.newsID)
+	}
+	close(c.send)
+}
+
+// Publish отправляет событие всем подписчикам новости. Отправка не блокируется:
+// клиент, не успевающий читать, отключается и должен перезагрузить комментарии.
+func (h *Hub) Publish(ev CommentEvent) {
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Marshal comment event: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients[ev.Comment.NewsID] {
+		select {
+		case c.send <- msg:
+		default:
+			log.Printf("Slow websocket client for news %d dropped", c.newsID)
+			c.slow = true
+			h.remove(c)
+		}
+	}
+}
+
+// Subscribers возвращает число подписчиков новости
+func (h *Hub) Subscribers(newsID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients[newsID])
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Поток только читает публичные комментарии, поэтому подключения с других сайтов допустимы
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// GET /comments/ws?news_id=1 — события комментариев новости в реальном времени
+func wsHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		newsID, err := strconv.Atoi(r.URL.Query().Get("news_id"))
+		if err != nil || newsID < 1 {
+			apierror.Write(w, r, apierror.BadRequest("news_id required"))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade уже ответил клиенту
+			return
+		}
+		c := &client{newsID: newsID, conn: conn, send: make(chan []byte, sendBuffer)}
+		hub.register(c)
+
+		go c.writePump()
+		c.readPump(hub)
+	}
+}
+
+// readPump читает входящие кадры, чтобы обрабатывать pong и закрытие соединения.
+// Сообщения от клиента не ожидаются и игнорируются.
+func (c *client) readPump(hub *Hub) {
+	defer func() {
+		hub.unregister(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump отправляет события и ping. Завершается, когда hub закрывает канал send.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				if c.slow {
+					c.conn.WriteMessage(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				}
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,13 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/config"
+	"news/pkg/health"
+	"news/pkg/metrics"
+	"news/pkg/middleware"
+	"news/pkg/openapi"
+	"news/pkg/server"
+	"news/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	_ "modernc.org/sqlite"
 )
 
@@ -16,38 +35,11 @@ type Comment struct {
 	NewsID    int       `json:"news_id"`
 	ParentID  *int      `json:"parent_id"`
 	Author    string    `json:"author"`
+	AuthorID  *int64    `json:"author_id"` // пользователь, добавивший комментарий через шлюз
 	Text      string    `json:"text"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// MIDDLEWARE
-
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
-
-func loggerMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := r.URL.Query().Get("request_id")
-		if reqID == "" {
-			reqID = fmt.Sprintf("req-%d", time.Now().UnixNano())
-		}
-		start := time.Now()
-		rw := &responseWriter{w, http.StatusOK}
-
-		next.ServeHTTP(rw, r)
-
-		log.Printf("[%s] COMMENTS | %s %s | ID: %s | Статус: %d | Время: %v",
-			time.Now().Format("15:04:05"), r.Method, r.URL.Path, reqID, rw.statusCode, time.Since(start))
-	})
-}
-
 // РАБОТА С БД
 
 func initDB(db *sql.DB) error {
@@ -57,15 +49,51 @@ func initDB(db *sql.DB) error {
         news_id INTEGER NOT NULL,
         parent_id INTEGER,
         author TEXT NOT NULL,
+        author_id INTEGER,
         text TEXT NOT NULL,
         created_at DATETIME NOT NULL
     );`
-	_, err := db.Exec(query)
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Базы, созданные до появления учетных записей, получают столбец author_id
+	var n int
+	err := db.QueryRow(`SELECT count(*) FROM pragma_table_info('comments') WHERE name = 'author_id'`).Scan(&n)
+	if err == nil && n == 0 {
+		_, err = db.Exec(`ALTER TABLE comments ADD COLUMN author_id INTEGER`)
+	}
 	return err
 }
 
+// Config - настройки сервиса комментариев
+type Config struct {
+	Addr           string         `json:"addr"`            // адрес HTTP сервера
+	Database       string         `json:"database"`        // файл SQLite
+	InternalSecret string         `json:"internal_secret"` // секрет подписи заголовков шлюза, обычно COMMENTS_INTERNAL_SECRET
+	Tracing        tracing.Config `json:"tracing"`
+}
+
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.Addr("addr", c.Addr),
+		config.Required("database", c.Database),
+		auth.CheckSecret("internal_secret", c.InternalSecret),
+	)
+}
+
 func main() {
-	db, err := sql.Open("sqlite", "./comments.db")
+	slog.SetDefault(middleware.NewLogger("comments"))
+
+	// Настройки: comments.json, переменные COMMENTS_* и флаги
+	cfg := Config{
+		Addr:     ":8081",
+		Database: "./comments.db",
+		Tracing:  tracing.ConfigFromEnv(),
+	}
+	config.MustLoad(&cfg, config.Options{Name: "comments", EnvPrefix: "COMMENTS", File: "comments.json", Args: os.Args[1:]})
+
+	db, err := sql.Open("sqlite", cfg.Database)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -75,65 +103,212 @@ func main() {
 		log.Fatal(err)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/comments", commentsHandler(db))
+	shutdownTracing, err := tracing.Setup("comments", cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	fmt.Println("CommentsService запущен на", cfg.Addr)
+	if err := server.Run(server.New(cfg.Addr, handler(db, NewHub(), cfg.InternalSecret, openapi.DefaultOptions()))); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	wrappedMux := loggerMiddleware(mux)
+// handler возвращает обработчики сервиса с проверкой запросов по спецификации
+// OpenAPI; secret проверяет подпись заголовков шлюза
+func handler(db *sql.DB, hub *Hub, secret string, opts openapi.Options) http.Handler {
+	spec := openapi.MustLoad(openapi.Comments)
+	mux := routes(db, hub)
+	mux.Handle("/openapi.json", spec.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.New().Add("sqlite", func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, "SELECT 1 FROM comments LIMIT 1")
+		return err
+	}).Handler())
+	// Клиента определяет шлюз и передает в подписанных заголовках X-Auth-*
+	var h http.Handler = spec.Middleware(opts)(auth.Trusted(secret)(mux))
+	h = metrics.Middleware(spec.Route)(h)
+	h = middleware.Timeout(spec.Timeout(middleware.DefaultTimeout))(h)
+	h = middleware.Stack(slog.Default())(h)
+	return tracing.Middleware(spec.Route)(h)
+}
 
-	fmt.Println("CommentsService запущен на :8081")
-	log.Fatal(http.ListenAndServe(":8081", wrappedMux))
+// routes регистрирует обработчики сервиса
+func routes(db *sql.DB, hub *Hub) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/comments", commentsHandler(db, hub))
+	// Правка и удаление - модерация
+	mux.Handle("/comments/", auth.Require(auth.RoleModerator)(commentHandler(db, hub)))
+	mux.HandleFunc("/comments/stats", statsHandler(db))
+	mux.HandleFunc("/comments/ws", wsHandler(hub))
+	return mux
 }
 
-func commentsHandler(db *sql.DB) http.HandlerFunc {
+func commentsHandler(db *sql.DB, hub *Hub) http.HandlerFunc {
+	// Комментирует клиент шлюза с ролью commenter, читать может любой
+	add := auth.Require(auth.RoleCommenter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addComment(w, r, db, hub)
+	}))
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			addComment(w, r, db)
+			add.ServeHTTP(w, r)
 		case http.MethodGet:
 			getComments(w, r, db)
 		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			apierror.Write(w, r, apierror.MethodNotAllowed())
+		}
+	}
+}
+
+// PUT /comments/{id} {"text": "..."} — правка, DELETE /comments/{id} — удаление
+func commentHandler(db *sql.DB, hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comments/"))
+		if err != nil || id < 1 {
+			apierror.Write(w, r, apierror.BadRequest("invalid comment id"))
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			editComment(w, r, db, hub, id)
+		case http.MethodDelete:
+			deleteComment(w, r, db, hub, id)
+		default:
+			apierror.Write(w, r, apierror.MethodNotAllowed())
 		}
 	}
 }
 
-func addComment(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+// dbSpan начинает спан запроса к SQLite
+func dbSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "CommentsService", "sqlite."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite, attribute.String("db.operation.name", op)))
+}
+
+func loadComment(ctx context.Context, db *sql.DB, id int) (Comment, error) {
+	ctx, span := dbSpan(ctx, "loadComment")
+	var c Comment
+	err := db.QueryRowContext(ctx,
+		`SELECT id, news_id, parent_id, author, author_id, text, created_at FROM comments WHERE id = ?`, id,
+	).Scan(&c.ID, &c.NewsID, &c.ParentID, &c.Author, &c.AuthorID, &c.Text, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		tracing.End(span, nil) // отсутствие комментария - не ошибка запроса
+	} else {
+		tracing.End(span, err)
+	}
+	return c, err
+}
+
+func editComment(w http.ResponseWriter, r *http.Request, db *sql.DB, hub *Hub, id int) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+		apierror.Write(w, r, apierror.BadRequest("text required"))
+		return
+	}
+
+	ctx, span := dbSpan(r.Context(), "editComment")
+	res, err := db.ExecContext(ctx, `UPDATE comments SET text = ? WHERE id = ?`, req.Text, id)
+	tracing.End(span, err)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		apierror.Write(w, r, apierror.NotFound("comment not found"))
+		return
+	}
+
+	c, err := loadComment(r.Context(), db, id)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	log.Printf("Comment %d edited by %s", id, actor(r))
+	hub.Publish(CommentEvent{Type: EventEdited, Comment: c})
+	writeJSON(w, c)
+}
+
+func deleteComment(w http.ResponseWriter, r *http.Request, db *sql.DB, hub *Hub, id int) {
+	c, err := loadComment(r.Context(), db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("comment not found"))
+		return
+	}
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	ctx, span := dbSpan(r.Context(), "deleteComment")
+	_, err = db.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, id)
+	tracing.End(span, err)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	log.Printf("Comment %d deleted by %s", id, actor(r))
+	hub.Publish(CommentEvent{Type: EventDeleted, Comment: c})
+	writeJSON(w, map[string]any{"status": "ok", "id": id})
+}
+
+func addComment(w http.ResponseWriter, r *http.Request, db *sql.DB, hub *Hub) {
 	var c Comment
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidJSON())
 		return
 	}
+	// Автор - клиент из заголовков шлюза, переданные в теле author и author_id не
+	// используются. author - имя на момент публикации: его видят подписчики
+	// WebSocket, а шлюз при чтении заменяет его текущим именем пользователя.
+	p, _ := auth.FromContext(r.Context())
+	c.Author, c.AuthorID = p.DisplayName(), nil
+	if id, ok := auth.UserID(p.Subject); ok {
+		c.AuthorID = &id
+	}
 
-	res, err := db.Exec(
-		`INSERT INTO comments (news_id, parent_id, author, text, created_at)
-         VALUES (?, ?, ?, ?, datetime('now'))`,
-		c.NewsID, c.ParentID, c.Author, c.Text,
+	ctx, span := dbSpan(r.Context(), "addComment")
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO comments (news_id, parent_id, author, author_id, text, created_at)
+         VALUES (?, ?, ?, ?, ?, datetime('now'))`,
+		c.NewsID, c.ParentID, c.Author, c.AuthorID, c.Text,
 	)
+	tracing.End(span, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Internal(w, r, err)
 		return
 	}
 
 	id, _ := res.LastInsertId()
+	if created, err := loadComment(r.Context(), db, int(id)); err == nil {
+		hub.Publish(CommentEvent{Type: EventCreated, Comment: created})
+	}
 	writeJSON(w, map[string]any{"status": "ok", "id": id})
 }
 
 func getComments(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	newsID := r.URL.Query().Get("news_id")
 	if newsID == "" {
-		http.Error(w, "news_id required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("news_id required"))
 		return
 	}
 
-	rows, err := db.Query(
-		`SELECT id, news_id, parent_id, author, text, created_at 
+	ctx, span := dbSpan(r.Context(), "getComments")
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, news_id, parent_id, author, author_id, text, created_at
          FROM comments 
          WHERE news_id = ? 
          ORDER BY created_at`,
 		newsID,
 	)
+	tracing.End(span, err)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Internal(w, r, err)
 		return
 	}
 	defer rows.Close()
@@ -141,8 +316,8 @@ func getComments(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	var comments []Comment
 	for rows.Next() {
 		var c Comment
-		if err := rows.Scan(&c.ID, &c.NewsID, &c.ParentID, &c.Author, &c.Text, &c.CreatedAt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := rows.Scan(&c.ID, &c.NewsID, &c.ParentID, &c.Author, &c.AuthorID, &c.Text, &c.CreatedAt); err != nil {
+			apierror.Internal(w, r, err)
 			return
 		}
 		comments = append(comments, c)
@@ -153,6 +328,68 @@ func getComments(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	writeJSON(w, comments)
 }
 
+// Активность обсуждения новости за период
+type NewsStats struct {
+	NewsID        int    `json:"news_id"`
+	Comments      int    `json:"comments"`
+	LastCommentAt string `json:"last_comment_at"`
+}
+
+// GET /comments/stats?hours=24&limit=10 — новости с наибольшим числом комментариев за период
+func statsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			apierror.Write(w, r, apierror.MethodNotAllowed())
+			return
+		}
+
+		hours, err := strconv.Atoi(r.URL.Query().Get("hours"))
+		if err != nil || hours < 1 {
+			hours = 24
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 10
+		}
+
+		ctx, span := dbSpan(r.Context(), "commentStats")
+		rows, err := db.QueryContext(ctx,
+			`SELECT news_id, count(*) AS cnt, max(created_at) AS last_at
+             FROM comments
+             WHERE created_at >= datetime('now', ?)
+             GROUP BY news_id
+             ORDER BY cnt DESC, last_at DESC
+             LIMIT ?`,
+			fmt.Sprintf("-%d hours", hours), limit,
+		)
+		tracing.End(span, err)
+		if err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+		defer rows.Close()
+
+		stats := []NewsStats{}
+		for rows.Next() {
+			var s NewsStats
+			if err := rows.Scan(&s.NewsID, &s.Comments, &s.LastCommentAt); err != nil {
+				apierror.Internal(w, r, err)
+				return
+			}
+			stats = append(stats, s)
+		}
+		writeJSON(w, stats)
+	}
+}
+
+// actor описывает клиента запроса для журнала модерации
+func actor(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Subject != "" {
+		return p.Subject + " (" + string(p.Role) + ")"
+	}
+	return "internal request"
+}
+
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
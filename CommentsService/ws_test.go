@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"news/pkg/auth"
+	"news/pkg/openapi"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func testServer(t *testing.T) (*httptest.Server, *Hub) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	// Каждое соединение с :memory: - отдельная БД
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, initDB(db))
+
+	hub := NewHub()
+	// Ответы сервиса проверяются по спецификации
+	srv := httptest.NewServer(handler(db, hub, testSecret, openapi.Options{ValidateResponses: true}))
+	t.Cleanup(srv.Close)
+	return srv, hub
+}
+
+// testSecret - внутренний секрет шлюза и сервиса в тестах
+const testSecret = "internal-secret-of-at-least-32-bytes"
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	return doAs(t, "", method, url, body)
+}
+
+// doAs выполняет запрос от клиента с ролью role, как его передает шлюз;
+// пустая роль - внутренний запрос без заголовков X-Auth-*
+func doAs(t *testing.T, role auth.Role, method, url, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if role != "" {
+		auth.SetTrusted(req.Header, auth.Principal{Subject: "key:1", Role: role, Method: auth.MethodAPIKey}, testSecret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestCommentsWebSocket(t *testing.T) {
+	srv, hub := testServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/comments/ws?news_id=7"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.Subscribers(7) == 1 }, time.Second, 10*time.Millisecond)
+
+	next := func() CommentEvent {
+		var ev CommentEvent
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		require.NoError(t, conn.ReadJSON(&ev))
+		return ev
+	}
+
+	// Комментарий к другой новости не приходит
+	doAs(t, auth.RoleCommenter, http.MethodPost, srv.URL+"/comments", `{"news_id": 8, "text": "мимо"}`)
+	resp := doAs(t, auth.RoleCommenter, http.MethodPost, srv.URL+"/comments", `{"news_id": 7, "text": "первый"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ev := next()
+	require.Equal(t, EventCreated, ev.Type)
+	require.Equal(t, "первый", ev.Comment.Text)
+	require.Equal(t, 7, ev.Comment.NewsID)
+	id := ev.Comment.ID
+
+	resp = do(t, http.MethodGet, srv.URL+"/comments?news_id=7", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(t, http.MethodGet, srv.URL+"/comments/stats", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Правка и удаление доступны только модераторам
+	resp = do(t, http.MethodPut, srv.URL+"/comments/"+strconv.Itoa(id), `{"text": "исправленный"}`)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doAs(t, auth.RoleCommenter, http.MethodDelete, srv.URL+"/comments/"+strconv.Itoa(id), "")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Заголовки шлюза без подписи при прямом обращении к сервису отклоняются
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+"/comments/"+strconv.Itoa(id), nil)
+	require.NoError(t, err)
+	req.Header.Set(auth.HeaderSubject, "key:1")
+	req.Header.Set(auth.HeaderRole, string(auth.RoleAdmin))
+	req.Header.Set(auth.HeaderMethod, auth.MethodAPIKey)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doAs(t, auth.RoleModerator, http.MethodPut, srv.URL+"/comments/"+strconv.Itoa(id), `{"text": "исправленный"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	ev = next()
+	require.Equal(t, EventEdited, ev.Type)
+	require.Equal(t, "исправленный", ev.Comment.Text)
+
+	resp = doAs(t, auth.RoleModerator, http.MethodDelete, srv.URL+"/comments/"+strconv.Itoa(id), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	ev = next()
+	require.Equal(t, EventDeleted, ev.Type)
+	require.Equal(t, id, ev.Comment.ID)
+
+	resp = doAs(t, auth.RoleModerator, http.MethodDelete, srv.URL+"/comments/"+strconv.Itoa(id), "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// После закрытия соединения подписка удаляется
+	conn.Close()
+	require.Eventually(t, func() bool { return hub.Subscribers(7) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := NewHub()
+	c := &client{newsID: 1, send: make(chan []byte, 1)}
+	hub.register(c)
+
+	ev := CommentEvent{Type: EventCreated, Comment: Comment{ID: 1, NewsID: 1}}
+	hub.Publish(ev)
+	hub.Publish(ev) // очередь переполнена
+
+	require.Equal(t, 0, hub.Subscribers(1))
+	require.True(t, c.slow)
+	<-c.send
+	_, ok := <-c.send
+	require.False(t, ok)
+}
+
+func TestWebSocketRequiresNewsID(t *testing.T) {
+	srv, _ := testServer(t)
+	resp := do(t, http.MethodGet, srv.URL+"/comments/ws", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHealth(t *testing.T) {
+	srv, _ := testServer(t)
+	resp := do(t, http.MethodGet, srv.URL+"/healthz", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(t, http.MethodGet, srv.URL+"/readyz", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCommentAuthorFromGateway(t *testing.T) {
+	srv, hub := testServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/comments/ws?news_id=3", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.Subscribers(3) == 1 }, time.Second, 10*time.Millisecond)
+
+	// Шлюз передает пользователя в доверенных заголовках; автор из тела не используется
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/comments",
+		strings.NewReader(`{"news_id": 3, "author": "admin", "author_id": 1, "text": "привет"}`))
+	require.NoError(t, err)
+	auth.SetTrusted(req.Header, auth.Principal{Subject: auth.UserSubject(9), Role: auth.RoleCommenter,
+		Method: auth.MethodSession, Name: "Алиса"}, testSecret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Подписчики WebSocket видят имя автора на момент публикации
+	var ev CommentEvent
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	require.NoError(t, conn.ReadJSON(&ev))
+	require.Equal(t, EventCreated, ev.Type)
+	require.Equal(t, "Алиса", ev.Comment.Author)
+	require.Equal(t, int64(9), *ev.Comment.AuthorID)
+
+	// Ключ без учетной записи пользователя комментирует под своим названием
+	req, err = http.NewRequest(http.MethodPost, srv.URL+"/comments", strings.NewReader(`{"news_id": 3, "text": "сводка"}`))
+	require.NoError(t, err)
+	auth.SetTrusted(req.Header, auth.Principal{Subject: "key:4", Role: auth.RoleCommenter,
+		Method: auth.MethodAPIKey, Name: "Бот новостей"}, testSecret)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	require.NoError(t, conn.ReadJSON(&ev))
+	require.Equal(t, "Бот новостей", ev.Comment.Author)
+	require.Nil(t, ev.Comment.AuthorID)
+
+	// Без заголовков шлюза комментировать нельзя, даже указав автора
+	resp = do(t, http.MethodPost, srv.URL+"/comments", `{"news_id": 3, "author": "аноним", "text": "аноним"}`)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = doAs(t, auth.RoleReader, http.MethodPost, srv.URL+"/comments", `{"news_id": 3, "text": "читатель"}`)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/comments?news_id=3")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var comments []Comment
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&comments))
+	require.Len(t, comments, 2)
+	require.Equal(t, "Алиса", comments[0].Author)
+	require.Equal(t, int64(9), *comments[0].AuthorID)
+}
+
+func TestInitDBAddsAuthorID(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE comments (id INTEGER PRIMARY KEY AUTOINCREMENT, news_id INTEGER NOT NULL,
+		parent_id INTEGER, author TEXT NOT NULL, text TEXT NOT NULL, created_at DATETIME NOT NULL)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO comments (news_id, author, text, created_at) VALUES (1, 'alice', 'старый', datetime('now'))`)
+	require.NoError(t, err)
+
+	require.NoError(t, initDB(db))
+	require.NoError(t, initDB(db)) // повторный запуск ничего не меняет
+	c, err := loadComment(context.Background(), db, 1)
+	require.NoError(t, err)
+	require.Equal(t, "alice", c.Author)
+	require.Nil(t, c.AuthorID)
+}
+
+func TestCommentStats(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	require.NoError(t, initDB(db))
+	srv := httptest.NewServer(handler(db, NewHub(), testSecret, openapi.Options{ValidateResponses: true}))
+	defer srv.Close()
+
+	// news_id -> возраст комментариев в часах
+	for newsID, ages := range map[int][]int{1: {1, 2}, 2: {1, 2, 3}, 3: {30, 31, 32, 33}} {
+		for _, age := range ages {
+			_, err := db.Exec(`INSERT INTO comments (news_id, author, text, created_at)
+				VALUES (?, 'alice', 'текст', datetime('now', ?))`, newsID, fmt.Sprintf("-%d hours", age))
+			require.NoError(t, err)
+		}
+	}
+	for i := 0; i < 120; i++ {
+		_, err := db.Exec(`INSERT INTO comments (news_id, author, text, created_at)
+			VALUES (?, 'bob', 'текст', datetime('now', '-100 hours'))`, 100+i)
+		require.NoError(t, err)
+	}
+
+	stats := func(query string) []NewsStats {
+		resp, err := http.Get(srv.URL + "/comments/stats" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var stats []NewsStats
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+		return stats
+	}
+
+	// По умолчанию - за сутки: старые комментарии новости 3 не учитываются
+	s := stats("")
+	require.Len(t, s, 2)
+	require.Equal(t, NewsStats{NewsID: 2, Comments: 3, LastCommentAt: s[0].LastCommentAt}, s[0])
+	require.Equal(t, 1, s[1].NewsID)
+
+	s = stats("?hours=48")
+	require.Len(t, s, 3)
+	require.Equal(t, 3, s[0].NewsID)
+	require.Equal(t, 4, s[0].Comments)
+
+	require.Len(t, stats("?hours=48&limit=1"), 1)
+	require.Len(t, stats("?hours=200"), 10)
+	require.Len(t, stats("?hours=200&limit=100"), 100)
+
+	// Значения вне диапазона отклоняет спецификация
+	resp := do(t, http.MethodGet, srv.URL+"/comments/stats?limit=101", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Обработчик без проверки по спецификации заменяет их значениями по умолчанию
+	for query, want := range map[string]int{"?hours=-5": 2, "?hours=200&limit=0": 10, "?hours=200&limit=101": 10} {
+		rec := httptest.NewRecorder()
+		statsHandler(db)(rec, httptest.NewRequest(http.MethodGet, "/comments/stats"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, query)
+		var s []NewsStats
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&s))
+		require.Len(t, s, want, query)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"news/pkg/auth"
+	"news/pkg/pipeline"
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineRequiresAdmin(t *testing.T) {
+	set, err := pipeline.Build(pipeline.Config{})
+	require.NoError(t, err)
+	secret := "internal-secret-of-at-least-32-bytes"
+	router := New(&postgres.NewsDb{}, WithPipeline(pipeline.NewRunner(set)), WithInternalSecret(secret)).Router()
+
+	do := func(role auth.Role) int {
+		req := httptest.NewRequest(http.MethodGet, "/pipeline/sources", nil)
+		if role != "" {
+			auth.SetTrusted(req.Header, auth.Principal{Subject: "key:1", Role: role, Method: auth.MethodAPIKey}, secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	require.Equal(t, http.StatusUnauthorized, do(""))
+	require.Equal(t, http.StatusForbidden, do(auth.RoleModerator))
+	require.Equal(t, http.StatusOK, do(auth.RoleAdmin))
+}
@@ -1,42 +1,144 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"news/pkg/apierror"
+	"news/pkg/auth"
+	"news/pkg/compress"
+	"news/pkg/config"
+	"news/pkg/health"
+	"news/pkg/httpcache"
+	"news/pkg/metrics"
+	"news/pkg/middleware"
+	"news/pkg/openapi"
+	"news/pkg/pipeline"
 	"news/pkg/postgres"
+	"news/pkg/stream"
+	"news/pkg/tracing"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// Политика кэширования: список новостей обновляется при каждом опросе лент,
+// поэтому клиент должен перепроверять его по ETag; отдельный пост почти не меняется.
+// ETag списка строится по NewsVersion; правки постов в обход NewsDb, не меняющие
+// updated_at, его не меняют.
+const (
+	newsCacheControl = "public, max-age=0, must-revalidate"
+	postCacheControl = "public, max-age=300"
 )
 
 // API приложения.
 type API struct {
-	R  *mux.Router     // маршрутизатор запросов
-	db postgres.NewsDb // база данных
+	R        *mux.Router      // маршрутизатор запросов
+	db       postgres.NewsDb  // база данных
+	ingest   *pipeline.Runner // конвейер обработки лент, может отсутствовать
+	trending TrendingConfig   // параметры /trending по умолчанию
+	stream   *stream.Broker   // поток новых постов, может отсутствовать
+	spec     *openapi.Spec    // спецификация API для проверки запросов
+	validate openapi.Options
+	ready    *health.Checker  // проверки зависимостей для /readyz
+	reload   *config.Reloader // перезагрузка настроек, может отсутствовать
+	secret   string           // секрет подписи доверенных заголовков шлюза
+}
+
+// Параметры расчета трендов по умолчанию, в часах.
+// Клиент может переопределить их параметрами window и baseline.
+type TrendingConfig struct {
+	Window   int `json:"window"`    // последнее окно
+	Baseline int `json:"baseline"`  // базовый период перед окном
+	MinCount int `json:"min_count"` // минимум постов с тегом в окне
+	Limit    int `json:"limit"`
+}
+
+// WithTrending задает параметры /trending по умолчанию.
+func WithTrending(cfg TrendingConfig) Option {
+	return func(api *API) {
+		if cfg.Window > 0 {
+			api.trending.Window = cfg.Window
+		}
+		if cfg.Baseline > 0 {
+			api.trending.Baseline = cfg.Baseline
+		}
+		if cfg.MinCount > 0 {
+			api.trending.MinCount = cfg.MinCount
+		}
+		if cfg.Limit > 0 {
+			api.trending.Limit = cfg.Limit
+		}
+	}
 }
 
-// Обертка для записи кода ответа (Response Status Code)
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+// Option - дополнительная зависимость API.
+type Option func(*API)
+
+// WithPipeline подключает эндпоинты проверки конвейера обработки лент.
+func WithPipeline(r *pipeline.Runner) Option {
+	return func(api *API) {
+		api.ingest = r
+	}
 }
 
-// Переопределяем метод WriteHeader, чтобы запомнить статус для логов
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// WithStream подключает поток новых постов /news/stream.
+func WithStream(b *stream.Broker) Option {
+	return func(api *API) {
+		api.stream = b
+	}
+}
+
+// WithOpenAPI задает режим проверки по спецификации, например проверку ответов в тестах.
+func WithOpenAPI(opts openapi.Options) Option {
+	return func(api *API) {
+		api.validate = opts
+	}
+}
+
+// WithReloader подключает /admin/reload.
+func WithReloader(r *config.Reloader) Option {
+	return func(api *API) {
+		api.reload = r
+	}
+}
+
+// WithInternalSecret задает секрет, которым шлюз подписывает заголовки X-Auth-*.
+// Без него запросы с заголовками шлюза отклоняются.
+func WithInternalSecret(secret string) Option {
+	return func(api *API) {
+		api.secret = secret
+	}
+}
+
+// WithCheck добавляет проверку зависимости в /readyz.
+func WithCheck(name string, check health.Check) Option {
+	return func(api *API) {
+		api.ready.Add(name, check)
+	}
 }
 
 // Конструктор API.
-func New(db *postgres.NewsDb) *API {
+func New(db *postgres.NewsDb, opts ...Option) *API {
 	api := API{}
 	api.db = *db
+	api.trending = TrendingConfig{Window: 24, Baseline: 7 * 24, MinCount: 2, Limit: 20}
+	api.spec = openapi.MustLoad(openapi.News)
+	api.validate = openapi.DefaultOptions()
+	api.ready = health.New().Add("postgres", db.Ping)
+	for _, opt := range opts {
+		opt(&api)
+	}
 	api.R = mux.NewRouter()
+	api.R.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.MethodNotAllowed())
+	})
 	api.endpoints()
 	return &api
 }
@@ -48,38 +150,117 @@ func (api *API) Router() *mux.Router {
 
 // Регистрация методов API в маршрутизаторе запросов.
 func (api *API) endpoints() {
-	// Подключаем наш логгер ко всем запросам через Middleware
-	api.R.Use(api.loggerMiddleware)
+	// Серверный спан на каждый запрос; родитель берется из заголовка traceparent
+	api.R.Use(tracing.Middleware(api.spec.Route))
+	// Сквозной ID запроса, журнал запросов и перехват паник
+	api.R.Use(middleware.Stack(slog.Default()))
+	// Ограничение времени обработки; поток /news/stream не ограничивается
+	api.R.Use(middleware.Timeout(api.spec.Timeout(middleware.DefaultTimeout)))
+	// Счетчики и длительность запросов по маршрутам спецификации
+	api.R.Use(metrics.Middleware(api.spec.Route))
+	// Сжатие ответов gzip/zstd по Accept-Encoding
+	api.R.Use(compress.Middleware)
+	// Проверка запросов по спецификации OpenAPI
+	api.R.Use(api.spec.Middleware(api.validate))
+	// Клиента определяет шлюз и передает в подписанных заголовках X-Auth-*
+	api.R.Use(auth.Trusted(api.secret))
+
+	// Спецификация
+	api.R.Handle("/openapi.json", api.spec.Handler()).Methods(http.MethodGet)
+	// Метрики Prometheus
+	api.R.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	// Живость процесса и готовность зависимостей
+	api.R.Handle("/healthz", health.Live()).Methods(http.MethodGet)
+	api.R.Handle("/readyz", api.ready.Handler()).Methods(http.MethodGet)
 
 	// Единый эндпоинт для новостей (поиск + пагинация)
 	api.R.HandleFunc("/news", api.getNews).Methods(http.MethodGet, http.MethodOptions)
 
+	// Сводные ленты RSS 2.0 и Atom с теми же фильтрами, что и /news
+	api.R.HandleFunc("/feed.rss", api.feedRSS).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
+	api.R.HandleFunc("/feed.atom", api.feedAtom).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
+
+	// Поток новых постов (Server-Sent Events)
+	if api.stream != nil {
+		api.R.Handle("/news/stream", stream.Handler(api.stream)).Methods(http.MethodGet)
+	}
+
+	// Популярные теги
+	api.R.HandleFunc("/tags", api.tags).Methods(http.MethodGet, http.MethodOptions)
+
+	// Растущие темы
+	api.R.HandleFunc("/trending", api.trendingTags).Methods(http.MethodGet, http.MethodOptions)
+
 	// Детальная новость
 	api.R.HandleFunc("/news/{id:[0-9]+}", api.postByID).Methods(http.MethodGet, http.MethodOptions)
 
+	// Проверка правил конвейера на последних загруженных элементах; только для администраторов
+	admin := auth.Require(auth.RoleAdmin)
+	if api.ingest != nil {
+		api.R.Handle("/pipeline/sources", admin(http.HandlerFunc(api.pipelineSources))).Methods(http.MethodGet, http.MethodOptions)
+		api.R.Handle("/pipeline/dry-run", admin(http.HandlerFunc(api.pipelineDryRun))).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
+	}
+
+	// Результат загрузки настроек и перезагрузка по запросу
+	if api.reload != nil {
+		api.R.Handle("/admin/reload", admin(api.reload.Handler())).Methods(http.MethodGet, http.MethodPost)
+	}
+
 	// Статика
 	api.R.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./webapp"))))
 }
 
 // Основной обработчик новостей
 func (api *API) getNews(w http.ResponseWriter, r *http.Request) {
-	sQuery := r.URL.Query().Get("s")     // Поиск
-	pageStr := r.URL.Query().Get("page") // Страница
+	sQuery := r.URL.Query().Get("s")      // Поиск
+	tag := r.URL.Query().Get("tag")       // Тег
+	lang := r.URL.Query().Get("lang")     // Язык
+	source := r.URL.Query().Get("source") // Лента
+	pageStr := r.URL.Query().Get("page")  // Страница
 
 	page, _ := strconv.Atoi(pageStr)
 	if page < 1 {
 		page = 1
 	}
 
+	// Набор полей, например fields=id,title,summary, чтобы не передавать тяжелый content
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	filter := postgres.NewsFilter{Search: sQuery, Tag: tag, Lang: lang, Source: source}
+
+	// Если у клиента актуальная страница, полная выборка не нужна
+	version, err := api.db.NewsVersion(r.Context(), filter)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	etag := httpcache.ETag(version, filter.Search, filter.Tag, filter.Lang, filter.Source, page, strings.Join(fields, ","))
+	if httpcache.Check(w, r, etag, newsCacheControl) {
+		return
+	}
+
 	// Вызываем универсальный метод из Postgres, который мы написали ранее
-	response, err := api.db.GetNews(sQuery, page)
+	response, err := api.db.GetNews(r.Context(), filter, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Internal(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if fields == nil {
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	news, err := projectPosts(response.News, fields)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"news": news, "pagination": response.Pagination})
 }
 
 // Получаем пост по ID
@@ -87,52 +268,154 @@ func (api *API) postByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("invalid id"))
 		return
 	}
 
-	post, err := api.db.PostByID(id)
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	post, err := api.db.PostByID(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		apierror.Write(w, r, apierror.NotFound("post not found"))
+		return
+	}
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	// ETag считается по содержимому: пост меняется редко, а ответ небольшой
+	var data any = post
+	if fields != nil {
+		if data, err = projectPost(post, fields); err != nil {
+			apierror.Internal(w, r, err)
+			return
+		}
+	}
+	if err := httpcache.WriteJSON(w, r, data, postCacheControl); err != nil {
+		apierror.Internal(w, r, err)
+	}
+}
+
+// Популярные теги: ?kind= ограничивает вид (keyword, company, person, place), ?limit= - количество
+func (api *API) tags(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	tags, err := api.db.Tags(r.Context(), r.URL.Query().Get("kind"), limit)
 	if err != nil {
-		http.Error(w, "post not found", http.StatusNotFound)
+		apierror.Internal(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(post)
+	json.NewEncoder(w).Encode(tags)
 }
 
-// Middleware для Request ID и Логирования
-func (api *API) loggerMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Сквозной ID: получаем или генерируем
-		reqID := r.URL.Query().Get("request_id")
-		if len(reqID) < 6 {
-			reqID = fmt.Sprintf("req-%d", time.Now().UnixNano())
-		}
+// Растущие теги: ?window= и ?baseline= в часах, ?kind=, ?limit=
+func (api *API) trendingTags(w http.ResponseWriter, r *http.Request) {
+	cfg, opts, apiErr := api.trendOptions(r.URL.Query())
+	if apiErr != nil {
+		apierror.Write(w, r, apiErr)
+		return
+	}
+
+	trends, err := api.db.Trending(r.Context(), opts)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
 
-		// Записываем ID в контекст
-		ctx := context.WithValue(r.Context(), "request_id", reqID)
-
-		// Добавляем ID в заголовок ответа
-		w.Header().Set("X-Request-ID", reqID)
-
-		// 2. Подготовка к логам
-		start := time.Now()
-		rw := &responseWriter{w, http.StatusOK}
-
-		// Передаем управление дальше
-		next.ServeHTTP(rw, r.WithContext(ctx))
-
-		// 3. Вывод лога (время, IP, метод, код, ID)
-		log.Printf(
-			"[%s] IP: %s | %s %s | STATUS: %d | ID: %s | DUR: %v",
-			time.Now().Format("2006-01-02 15:04:05"),
-			r.RemoteAddr,
-			r.Method,
-			r.URL.Path,
-			rw.statusCode,
-			reqID,
-			time.Since(start),
-		)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"window":   cfg.Window,
+		"baseline": cfg.Baseline,
+		"trends":   trends,
 	})
 }
+
+// Границы параметров /trending: окно и базовый период в часах, число тегов в ответе.
+const (
+	maxTrendHours = 24 * 365
+	maxTrendLimit = 100
+)
+
+// trendOptions накладывает параметры запроса на настройки /trending по умолчанию.
+// Параметры проверяются в фиксированном порядке, чтобы ошибка не зависела от запроса.
+func (api *API) trendOptions(q url.Values) (TrendingConfig, postgres.TrendOptions, *apierror.Error) {
+	cfg := api.trending
+	params := []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"window", &cfg.Window, maxTrendHours},
+		{"baseline", &cfg.Baseline, maxTrendHours},
+		{"limit", &cfg.Limit, maxTrendLimit},
+	}
+	for _, p := range params {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > p.max {
+				return cfg, postgres.TrendOptions{}, apierror.BadRequest("invalid " + p.name)
+			}
+			*p.dst = n
+		}
+	}
+	if cfg.Baseline <= cfg.Window {
+		return cfg, postgres.TrendOptions{}, apierror.BadRequest("baseline must be greater than window")
+	}
+	return cfg, postgres.TrendOptions{
+		Window:   time.Duration(cfg.Window) * time.Hour,
+		Baseline: time.Duration(cfg.Baseline) * time.Hour,
+		Kind:     q.Get("kind"),
+		MinCount: cfg.MinCount,
+		Limit:    cfg.Limit,
+	}, nil
+}
+
+// Список лент, для которых доступен dry-run
+func (api *API) pipelineSources(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"sources": api.ingest.Sources()})
+}
+
+// Показывает, какие из последних элементов ленты прошли бы конвейер и почему.
+// GET ?source=URL проверяет настроенный конвейер, POST {"source", "stages"} - предлагаемый.
+func (api *API) pipelineDryRun(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Source string                 `json:"source"`
+		Stages []pipeline.StageConfig `json:"stages"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON())
+			return
+		}
+	} else {
+		req.Source = r.URL.Query().Get("source")
+	}
+	if req.Source == "" {
+		apierror.Write(w, r, apierror.BadRequest("source required"))
+		return
+	}
+
+	report, err := api.ingest.DryRun(req.Source, req.Stages)
+	if errors.Is(err, pipeline.ErrUnknownSource) {
+		apierror.Write(w, r, apierror.NotFound(err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
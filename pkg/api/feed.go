@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"news/pkg/apierror"
+	"news/pkg/postgres"
+)
+
+const (
+	feedTitle       = "Агрегатор новостей"
+	feedDescription = "Последние новости из всех подключенных лент"
+	feedMaxAge      = 5 * time.Minute // совпадает с периодом опроса лент по умолчанию
+)
+
+// ---- RSS 2.0 ----
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Guid        rssGuid  `xml:"guid"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// ---- Atom ----
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// Лента RSS 2.0: ?s=, ?tag=, ?source=, ?lang= как у /news, ?limit= - количество постов
+func (api *API) feedRSS(w http.ResponseWriter, r *http.Request) {
+	api.feed(w, r, "application/rss+xml; charset=utf-8", renderRSS)
+}
+
+// Лента Atom с теми же параметрами, что и /feed.rss
+func (api *API) feedAtom(w http.ResponseWriter, r *http.Request) {
+	api.feed(w, r, "application/atom+xml; charset=utf-8", renderAtom)
+}
+
+// feed загружает посты по фильтрам запроса и отдает их в формате render
+func (api *API) feed(w http.ResponseWriter, r *http.Request, contentType string, render func(self string, posts []postgres.Post) any) {
+	q := r.URL.Query()
+	limit := 30
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			apierror.Write(w, r, apierror.BadRequest("invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	posts, err := api.db.LatestPosts(r.Context(), postgres.NewsFilter{
+		Search: q.Get("s"),
+		Tag:    q.Get("tag"),
+		Lang:   q.Get("lang"),
+		Source: q.Get("source"),
+	}, limit)
+	if err != nil {
+		apierror.Internal(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(feedMaxAge.Seconds())))
+	if modified := lastModified(posts); !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		if notModified(r, modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(render(selfURL(r), posts))
+}
+
+// renderRSS строит документ RSS 2.0
+func renderRSS(self string, posts []postgres.Post) any {
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       feedTitle,
+			Link:        siteURL(self),
+			Description: feedDescription,
+			Self:        atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if modified := lastModified(posts); !modified.IsZero() {
+		feed.Channel.LastBuildDate = modified.Format(time.RFC1123Z)
+	}
+	for _, p := range posts {
+		item := rssItem{
+			Title:       p.Title,
+			Link:        p.Link,
+			Description: p.Content,
+			PubDate:     time.Unix(p.PubTime, 0).UTC().Format(time.RFC1123Z),
+			Guid:        rssGuid{IsPermaLink: true, Value: p.Link},
+		}
+		if p.Summary != "" {
+			item.Description = p.Summary
+		}
+		for _, t := range p.Tags {
+			item.Categories = append(item.Categories, t.Name)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+// renderAtom строит документ Atom
+func renderAtom(self string, posts []postgres.Post) any {
+	updated := lastModified(posts)
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	feed := atomFeed{
+		Title:    feedTitle,
+		Subtitle: feedDescription,
+		ID:       self,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: siteURL(self), Rel: "alternate", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: feedTitle},
+	}
+	for _, p := range posts {
+		ts := time.Unix(p.PubTime, 0).UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        p.Link,
+			Updated:   ts,
+			Published: ts,
+			Link:      atomLink{Href: p.Link, Rel: "alternate"},
+			Author:    atomAuthor{Name: sourceName(p.Source)},
+			Content:   atomText{Type: "html", Value: p.Content},
+		}
+		if p.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: p.Summary}
+		}
+		for _, t := range p.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t.Name})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// lastModified - время самого свежего поста
+func lastModified(posts []postgres.Post) time.Time {
+	var max int64
+	for _, p := range posts {
+		if p.PubTime > max {
+			max = p.PubTime
+		}
+	}
+	if max == 0 {
+		return time.Time{}
+	}
+	return time.Unix(max, 0).UTC()
+}
+
+// notModified проверяет условие If-Modified-Since с точностью до секунды
+func notModified(r *http.Request, modified time.Time) bool {
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// selfURL восстанавливает адрес запроса с учетом прокси перед сервисом
+func selfURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	return u.String()
+}
+
+// siteURL - корень сайта, на котором опубликована лента
+func siteURL(self string) string {
+	u, err := url.Parse(self)
+	if err != nil {
+		return self
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+}
+
+// sourceName - имя ленты для поля author
+func sourceName(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if source == "" {
+		return feedTitle
+	}
+	return source
+}
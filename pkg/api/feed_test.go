@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+var feedPosts = []postgres.Post{
+	{
+		ID: 2, Title: "Новый процессор <AMD>", Content: "<p>Текст &amp; подробности</p>", Summary: "Кратко",
+		PubTime: 1714564800, Link: "https://3dnews.ru/2", Source: "https://3dnews.ru/breaking/rss/",
+		Tags: []postgres.Tag{{Name: "AMD", Kind: "company"}},
+	},
+	{ID: 1, Title: "Старая новость", Content: "Текст", PubTime: 1714478400, Link: "https://habr.com/1"},
+}
+
+func TestRenderRSS(t *testing.T) {
+	b, err := xml.Marshal(renderRSS("http://localhost/feed.rss?tag=AMD", feedPosts))
+	require.NoError(t, err)
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string   `xml:"title"`
+				Description string   `xml:"description"`
+				PubDate     string   `xml:"pubDate"`
+				Guid        string   `xml:"guid"`
+				Categories  []string `xml:"category"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(b, &doc))
+	require.Equal(t, "2.0", doc.Version)
+	require.True(t, bytes.Contains(b, []byte(`<link>http://localhost/</link>`)))
+	require.True(t, bytes.Contains(b, []byte(`<atom:link href="http://localhost/feed.rss?tag=AMD" rel="self"`)))
+	require.Equal(t, "Wed, 01 May 2024 12:00:00 +0000", doc.Channel.LastBuildDate)
+	require.Len(t, doc.Channel.Items, 2)
+	require.Equal(t, "Новый процессор <AMD>", doc.Channel.Items[0].Title)
+	require.Equal(t, "Кратко", doc.Channel.Items[0].Description)
+	require.Equal(t, []string{"AMD"}, doc.Channel.Items[0].Categories)
+	require.Equal(t, "https://habr.com/1", doc.Channel.Items[1].Guid)
+	require.Equal(t, "Текст", doc.Channel.Items[1].Description)
+}
+
+func TestRenderAtom(t *testing.T) {
+	b, err := xml.Marshal(renderAtom("http://localhost/feed.atom", feedPosts))
+	require.NoError(t, err)
+	require.True(t, bytes.Contains(b, []byte(`xmlns="http://www.w3.org/2005/Atom"`)))
+
+	var doc struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Author  string `xml:"author>name"`
+			Content struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(b, &doc))
+	require.Equal(t, "2024-05-01T12:00:00Z", doc.Updated)
+	require.Len(t, doc.Entries, 2)
+	require.Equal(t, "https://3dnews.ru/2", doc.Entries[0].ID)
+	require.Equal(t, "3dnews.ru", doc.Entries[0].Author)
+	require.Equal(t, "html", doc.Entries[0].Content.Type)
+	require.Equal(t, "<p>Текст &amp; подробности</p>", doc.Entries[0].Content.Value)
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Unix(1714564800, 0).UTC()
+	r := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+	require.False(t, notModified(r, modified))
+
+	r.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	require.True(t, notModified(r, modified))
+
+	r.Header.Set("If-Modified-Since", modified.Add(-time.Minute).Format(http.TimeFormat))
+	require.False(t, notModified(r, modified))
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+
+	"news/pkg/fields"
+	"news/pkg/postgres"
+)
+
+// Поля поста, которые можно запросить параметром fields
+var postFields = fields.NewSet("id", "title", "content", "summary", "pub_time", "link", "source", "lang", "tags")
+
+// parseFields разбирает список полей через запятую. Пустая строка означает все поля.
+func parseFields(s string) ([]string, error) {
+	return postFields.Parse(s)
+}
+
+// projectPosts оставляет в постах только указанные поля
+func projectPosts(posts []postgres.Post, f []string) ([]map[string]json.RawMessage, error) {
+	return fields.ProjectAll(posts, f)
+}
+
+func projectPost(p postgres.Post, f []string) (map[string]json.RawMessage, error) {
+	return fields.Project(p, f)
+}
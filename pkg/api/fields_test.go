@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields("")
+	require.NoError(t, err)
+	require.Nil(t, fields)
+
+	fields, err = parseFields("id, title,,summary")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "title", "summary"}, fields)
+
+	_, err = parseFields("id,password")
+	require.Error(t, err)
+}
+
+func TestProjectPost(t *testing.T) {
+	post := postgres.Post{ID: 7, Title: "Заголовок", Content: "Длинный текст", Summary: "Кратко"}
+	m, err := projectPost(post, []string{"id", "summary"})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": 7, "summary": "Кратко"}`, string(b))
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendOptions(t *testing.T) {
+	api := &API{trending: TrendingConfig{Window: 24, Baseline: 168, MinCount: 2, Limit: 20}}
+
+	cfg, opts, err := api.trendOptions(url.Values{"window": {"6"}, "kind": {"company"}})
+	require.Nil(t, err)
+	require.Equal(t, 6, cfg.Window)
+	require.Equal(t, 6*time.Hour, opts.Window)
+	require.Equal(t, 168*time.Hour, opts.Baseline)
+	require.Equal(t, "company", opts.Kind)
+	require.Equal(t, 2, opts.MinCount)
+	require.Equal(t, 20, opts.Limit)
+	require.Equal(t, 24, api.trending.Window) // настройки по умолчанию не меняются
+
+	for _, q := range []string{"window=0", "baseline=abc", "limit=101", "window=200", "window=48&baseline=48"} {
+		values, _ := url.ParseQuery(q)
+		_, _, err := api.trendOptions(values)
+		require.NotNil(t, err, q)
+	}
+}
+
+func TestTrendingTagsBadRequest(t *testing.T) {
+	api := &API{trending: TrendingConfig{Window: 24, Baseline: 168, MinCount: 2, Limit: 20}}
+
+	rec := httptest.NewRecorder()
+	api.trendingTags(rec, httptest.NewRequest(http.MethodGet, "/trending?window=-1", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "invalid window")
+
+	// Ошибка не зависит от порядка обхода параметров
+	for range 10 {
+		rec = httptest.NewRecorder()
+		api.trendingTags(rec, httptest.NewRequest(http.MethodGet, "/trending?limit=0&baseline=0&window=0", nil))
+		require.Contains(t, rec.Body.String(), "invalid window")
+	}
+}
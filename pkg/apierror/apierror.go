@@ -0,0 +1,157 @@
+// Package apierror - единый формат ошибок HTTP API всех сервисов:
+//
+//	{"error": {"code": "not_found", "message": "post not found", "request_id": "req-1", "details": ...}}
+//
+// Код - стабильный машиночитаемый идентификатор, сообщение - для людей.
+// Внутренние ошибки (БД и т.п.) пишутся в журнал и не передаются клиенту.
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Коды ошибок
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidJSON      = "invalid_json"
+	CodeValidation       = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeRejected         = "content_rejected" // комментарий не прошел цензуру
+	CodeInternal         = "internal"
+	CodeUnavailable      = "upstream_unavailable" // зависимый сервис недоступен
+	CodeUpstream         = "upstream_error"       // зависимый сервис вернул некорректный ответ
+	CodeTimeout          = "timeout"              // запрос не обработан за отведенное время
+	CodeUnauthorized     = "unauthorized"         // нет учетных данных или они неверны
+	CodeForbidden        = "forbidden"            // роли клиента недостаточно
+	CodeConflict         = "conflict"             // ресурс уже существует
+)
+
+// Error - ошибка API
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// envelope - тело ответа с ошибкой
+type envelope struct {
+	Error *Error `json:"error"`
+}
+
+// New создает ошибку
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails возвращает копию ошибки с дополнительными сведениями
+func (e *Error) WithDetails(details any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Конструкторы частых ошибок
+func BadRequest(message string) *Error { return New(http.StatusBadRequest, CodeBadRequest, message) }
+func InvalidJSON() *Error              { return New(http.StatusBadRequest, CodeInvalidJSON, "invalid json") }
+func NotFound(message string) *Error   { return New(http.StatusNotFound, CodeNotFound, message) }
+func MethodNotAllowed() *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+}
+func Unavailable(service string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, service+" unreachable")
+}
+func Timeout() *Error { return New(http.StatusGatewayTimeout, CodeTimeout, "request timed out") }
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+func Forbidden(message string) *Error { return New(http.StatusForbidden, CodeForbidden, message) }
+func Conflict(message string) *Error  { return New(http.StatusConflict, CodeConflict, message) }
+
+// Write отправляет ошибку клиенту. ID запроса берется из заголовка ответа X-Request-ID,
+// который выставляет middleware, или из параметра request_id.
+func Write(w http.ResponseWriter, r *http.Request, e *Error) {
+	if e.RequestID == "" {
+		c := *e
+		c.RequestID = requestID(w, r)
+		e = &c
+	}
+	status := e.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(envelope{Error: e})
+}
+
+// Internal пишет err в журнал и отвечает клиенту 500 без подробностей
+func Internal(w http.ResponseWriter, r *http.Request, err error) {
+	reqID := requestID(w, r)
+	log.Printf("Internal error | %s %s | ID: %s | %v", r.Method, r.URL.Path, reqID, err)
+	Write(w, r, &Error{
+		Status:    http.StatusInternalServerError,
+		Code:      CodeInternal,
+		Message:   "internal error",
+		RequestID: reqID,
+	})
+}
+
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get("X-Request-ID"); id != "" {
+		return id
+	}
+	if r != nil {
+		return r.URL.Query().Get("request_id")
+	}
+	return ""
+}
+
+// FromResponse читает ошибку из ответа зависимого сервиса. Если тело не в формате
+// конверта, код выводится из статуса, чтобы клиент все равно получил исходный статус.
+func FromResponse(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var env envelope
+	if err := json.Unmarshal(body, &env); err == nil && env.Error != nil && env.Error.Code != "" {
+		env.Error.Status = resp.StatusCode
+		return env.Error
+	}
+	return New(resp.StatusCode, codeForStatus(resp.StatusCode), http.StatusText(resp.StatusCode))
+}
+
+// Forward передает клиенту ошибку зависимого сервиса, сохраняя ее статус и код
+func Forward(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	Write(w, r, FromResponse(resp))
+}
+
+func codeForStatus(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status >= 400 && status < 500:
+		return CodeBadRequest
+	case status == http.StatusServiceUnavailable || status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return CodeUnavailable
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,56 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-123")
+	r := httptest.NewRequest(http.MethodGet, "/news/1", nil)
+
+	Write(w, r, NotFound("post not found").WithDetails(map[string]int{"id": 1}))
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"error": {"code": "not_found", "message": "post not found",
+		"request_id": "req-123", "details": {"id": 1}}}`, w.Body.String())
+}
+
+func TestInternalHidesCause(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/news?request_id=req-777", nil)
+
+	Internal(w, r, errors.New("pq: password authentication failed"))
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotContains(t, w.Body.String(), "password")
+	var env struct{ Error Error }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	require.Equal(t, CodeInternal, env.Error.Code)
+	require.Equal(t, "req-777", env.Error.RequestID)
+}
+
+func TestFromResponse(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Body:       io.NopCloser(strings.NewReader(`{"error": {"code": "content_rejected", "message": "inappropriate content"}}`)),
+	}
+	e := FromResponse(resp)
+	require.Equal(t, http.StatusBadRequest, e.Status)
+	require.Equal(t, CodeRejected, e.Code)
+
+	// Ответ не в формате конверта
+	resp = &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("oops"))}
+	e = FromResponse(resp)
+	require.Equal(t, http.StatusBadGateway, e.Status)
+	require.Equal(t, CodeUnavailable, e.Code)
+}
@@ -0,0 +1,191 @@
+// Package auth - аутентификация и роли. Шлюз проверяет API ключ или JWT,
+// сравнивает роль с минимальной ролью маршрута и передает результат внутренним
+// сервисам в доверенных заголовках X-Auth-*. Шлюз подписывает их общим с
+// сервисами внутренним секретом, поэтому сервисы верят подписанным заголовкам
+// без повторной проверки учетных данных, но роль для своих операций проверяют
+// сами (Require).
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"news/pkg/apierror"
+)
+
+// Role - роль клиента. Роли упорядочены: каждая следующая включает права предыдущих.
+type Role string
+
+const (
+	RoleReader    Role = "reader"    // чтение новостей и комментариев
+	RoleCommenter Role = "commenter" // добавление комментариев
+	RoleModerator Role = "moderator" // управление комментариями
+	RoleAdmin     Role = "admin"     // ключи, настройки, состояние сервисов
+)
+
+// Roles - все роли по возрастанию прав
+var Roles = []Role{RoleReader, RoleCommenter, RoleModerator, RoleAdmin}
+
+func (r Role) level() int {
+	for i, role := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Valid сообщает, известна ли роль
+func (r Role) Valid() bool { return r.level() > 0 }
+
+// Allows сообщает, достаточно ли роли r для действия, требующего min
+func (r Role) Allows(min Role) bool {
+	return min == "" || (r.Valid() && r.level() >= min.level())
+}
+
+// Способы аутентификации
+const (
+	MethodAnonymous = "anonymous"
+	MethodAPIKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodSession   = "session"
+)
+
+// Principal - кто выполняет запрос
+type Principal struct {
+	Subject string // ID пользователя или ключа; пусто для анонимного клиента
+	Role    Role   // пусто, если анонимным клиентам ничего не разрешено
+	Method  string
+	Name    string // отображаемое имя пользователя или название ключа; может быть пустым
+}
+
+// DisplayName - имя клиента для показа другим: Name, а если оно не задано - Subject
+func (p Principal) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Subject
+}
+
+// UserSubject - Subject клиента, вошедшего под учетной записью пользователя
+func UserSubject(id int64) string { return "user:" + strconv.FormatInt(id, 10) }
+
+// UserID возвращает ID пользователя из Subject; false - клиент не пользователь
+// (API ключ, анонимный запрос)
+func UserID(subject string) (int64, bool) {
+	s, ok := strings.CutPrefix(subject, "user:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil && id > 0
+}
+
+type contextKey struct{ name string }
+
+var principalKey = &contextKey{"principal"}
+
+// WithPrincipal сохраняет клиента в контексте
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext возвращает клиента из контекста
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+// Ошибки аутентификации
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator определяет клиента по заголовкам запроса
+type Authenticator struct {
+	Keys      KeyLookup // хранилище API ключей; nil - ключи не принимаются
+	Sessions  KeyLookup // сессии пользователей; nil - сессии не принимаются
+	Tokens    *Signer   // проверка JWT; nil - токены не принимаются
+	AdminKey  string    // статический ключ администратора для первичной настройки
+	Anonymous Role      // роль клиента без учетных данных; пусто - доступ запрещен
+}
+
+// Authenticate проверяет Authorization: Bearer <JWT, ключ или сессия> либо X-API-Key.
+// Без учетных данных возвращается анонимный клиент.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	credential, err := Credential(r)
+	if errors.Is(err, ErrNoCredentials) {
+		return Principal{Role: a.Anonymous, Method: MethodAnonymous}, nil
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if a.AdminKey != "" && equal(credential, a.AdminKey) {
+		return Principal{Subject: "admin-key", Role: RoleAdmin, Method: MethodAPIKey}, nil
+	}
+	// JWT состоит из трех частей через точку, в ключах точек нет
+	if strings.Count(credential, ".") == 2 {
+		if a.Tokens == nil {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return a.Tokens.Verify(credential)
+	}
+	lookup := a.Keys
+	if strings.HasPrefix(credential, SessionPrefix) {
+		lookup = a.Sessions
+	}
+	if lookup == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return lookup.LookupKey(r.Context(), HashKey(credential))
+}
+
+// Credential возвращает учетные данные запроса: X-API-Key или Authorization: Bearer
+func Credential(r *http.Request) (string, error) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key, nil
+	}
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		return "", ErrNoCredentials
+	}
+	scheme, value, _ := strings.Cut(h, " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(value) == "" {
+		return "", ErrInvalidCredentials
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// Middleware проверяет доступ к маршруту: role возвращает минимальную роль
+// запроса, пусто - маршрут открыт всем. Без учетных данных - 401, при
+// недостаточной роли - 403.
+func (a *Authenticator) Middleware(role func(r *http.Request) Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := a.Authenticate(r)
+			if err != nil {
+				unauthorized(w, r, "invalid credentials")
+				return
+			}
+			if min := role(r); !p.Role.Allows(min) {
+				if p.Method == MethodAnonymous {
+					unauthorized(w, r, "authentication required")
+					return
+				}
+				apierror.Write(w, r, apierror.Forbidden("role "+string(min)+" required"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="gonews"`)
+	apierror.Write(w, r, apierror.Unauthorized(message))
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news/pkg/apierror"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+type keys map[string]Role
+
+func (k keys) LookupKey(_ context.Context, hash string) (Principal, error) {
+	for key, role := range k {
+		if HashKey(key) == hash {
+			return Principal{Subject: "key:" + key[:6], Role: role, Method: MethodAPIKey}, nil
+		}
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+func TestRoleAllows(t *testing.T) {
+	require.True(t, RoleAdmin.Allows(RoleModerator))
+	require.True(t, RoleCommenter.Allows(RoleReader))
+	require.True(t, RoleReader.Allows(""))
+	require.True(t, Role("").Allows(""))
+	require.False(t, RoleCommenter.Allows(RoleModerator))
+	require.False(t, Role("").Allows(RoleReader))
+	require.False(t, Role("root").Allows(RoleReader))
+}
+
+func TestUserID(t *testing.T) {
+	id, ok := UserID(UserSubject(42))
+	require.True(t, ok)
+	require.Equal(t, int64(42), id)
+	for _, s := range []string{"", "key:3", "user:", "user:x", "user:-1"} {
+		_, ok := UserID(s)
+		require.False(t, ok, s)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	_, err := NewSigner("short")
+	require.Error(t, err)
+
+	s, err := NewSigner(secret)
+	require.NoError(t, err)
+	token, err := s.Issue("user-1", RoleCommenter)
+	require.NoError(t, err)
+	p, err := s.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, Principal{Subject: "user-1", Role: RoleCommenter, Method: MethodJWT}, p)
+
+	// Чужой секрет
+	other, _ := NewSigner(strings.Repeat("x", MinSecretSize))
+	_, err = other.Verify(token)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Истекший токен
+	s.TTL = -time.Minute
+	expired, err := s.Issue("user-1", RoleAdmin)
+	require.NoError(t, err)
+	_, err = s.Verify(expired)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Токен без подписи не принимается
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": "user-1", "role": "admin", "iss": "gonews", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = s.Verify(none)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestMiddleware(t *testing.T) {
+	signer, err := NewSigner(secret)
+	require.NoError(t, err)
+	commenterKey, err := GenerateKey()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(commenterKey, KeyPrefix))
+
+	session, err := GenerateSession()
+	require.NoError(t, err)
+
+	authn := &Authenticator{
+		Keys:      keys{commenterKey: RoleCommenter},
+		Sessions:  keys{session: RoleCommenter},
+		Tokens:    signer,
+		AdminKey:  "admin-secret",
+		Anonymous: RoleReader,
+	}
+	roles := map[string]Role{"/news": RoleReader, "/comment/add": RoleCommenter, "/status": RoleAdmin}
+	h := authn.Middleware(func(r *http.Request) Role { return roles[r.URL.Path] })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := FromContext(r.Context())
+			json.NewEncoder(w).Encode(p)
+		}))
+
+	do := func(path string, header ...string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			var e struct{ Error apierror.Error }
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&e))
+			return rec.Code, e.Error.Code
+		}
+		var p Principal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+		return rec.Code, string(p.Role)
+	}
+
+	code, role := do("/news")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "reader", role)
+
+	code, errCode := do("/comment/add")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Equal(t, apierror.CodeUnauthorized, errCode)
+
+	code, role = do("/comment/add", "X-API-Key", commenterKey)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "commenter", role)
+
+	// Сессии ищутся отдельно от ключей
+	code, role = do("/comment/add", "Authorization", "Bearer "+session)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "commenter", role)
+	authn.Sessions = nil
+	code, _ = do("/comment/add", "Authorization", "Bearer "+session)
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, errCode = do("/status", "Authorization", "Bearer "+commenterKey)
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, apierror.CodeForbidden, errCode)
+
+	code, _ = do("/status", "Authorization", "Bearer admin-secret")
+	require.Equal(t, http.StatusOK, code)
+
+	token, err := signer.Issue("user-7", RoleAdmin)
+	require.NoError(t, err)
+	code, _ = do("/status", "Authorization", "Bearer "+token)
+	require.Equal(t, http.StatusOK, code)
+
+	// Неверные учетные данные отклоняются даже на открытых маршрутах
+	code, _ = do("/news", "X-API-Key", "gnk_unknown")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = do("/news", "Authorization", "Basic dXNlcjpwYXNz")
+	require.Equal(t, http.StatusUnauthorized, code)
+}
+
+// testSecret - внутренний секрет шлюза и сервисов в тестах
+const testSecret = "internal-secret-of-at-least-32-bytes"
+
+func TestTrustedHeaders(t *testing.T) {
+	got := make(chan Principal, 1)
+	backend := httptest.NewServer(Trusted(testSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"))
+		p, _ := FromContext(r.Context())
+		got <- p
+	})))
+	defer backend.Close()
+	client := &http.Client{Transport: Transport(nil, testSecret)}
+
+	send := func(ctx context.Context) Principal {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, nil)
+		require.NoError(t, err)
+		// Подделанные клиентом заголовки не доходят до сервиса
+		req.Header.Set(HeaderRole, "admin")
+		req.Header.Set(HeaderMethod, MethodJWT)
+		req.Header.Set("Authorization", "Bearer something")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return <-got
+	}
+
+	p := Principal{Subject: "key:3", Role: RoleModerator, Method: MethodAPIKey, Name: "Бот / модератор"}
+	require.Equal(t, p, send(WithPrincipal(context.Background(), p)))
+	require.Equal(t, Principal{Method: MethodAnonymous}, send(context.Background()))
+}
+
+func TestTrustedSignature(t *testing.T) {
+	h := Trusted(testSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, "/comments", nil)
+		req.Header = header
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	admin := Principal{Subject: "key:1", Role: RoleAdmin, Method: MethodAPIKey}
+
+	signed := http.Header{}
+	SetTrusted(signed, admin, testSecret)
+	require.Equal(t, http.StatusOK, do(signed))
+
+	// Прямой запрос с заголовками шлюза без подписи
+	unsigned := signed.Clone()
+	unsigned.Del(HeaderSignature)
+	require.Equal(t, http.StatusUnauthorized, do(unsigned))
+
+	// Подпись не переносится на другую роль
+	forged := http.Header{}
+	SetTrusted(forged, Principal{Subject: "key:1", Role: RoleReader, Method: MethodAPIKey}, testSecret)
+	forged.Set(HeaderRole, string(RoleAdmin))
+	require.Equal(t, http.StatusUnauthorized, do(forged))
+
+	// Подпись чужим секретом
+	other := http.Header{}
+	SetTrusted(other, admin, "another-secret-of-at-least-32-bytes")
+	require.Equal(t, http.StatusUnauthorized, do(other))
+
+	require.Error(t, CheckSecret("internal_secret", ""))
+	require.Error(t, CheckSecret("internal_secret", "short"))
+	require.NoError(t, CheckSecret("internal_secret", testSecret))
+}
+
+func TestRequire(t *testing.T) {
+	h := Trusted(testSecret)(Require(RoleModerator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	do := func(role Role, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/comments/1", nil)
+		if method != "" {
+			SetTrusted(req.Header, Principal{Subject: "user:1", Role: role, Method: method}, testSecret)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Прямой запрос в обход шлюза
+	rec := do("", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	require.Equal(t, http.StatusForbidden, do(RoleCommenter, MethodSession).Code)
+	require.Equal(t, http.StatusForbidden, do("root", MethodJWT).Code) // неизвестная роль
+	require.Equal(t, http.StatusOK, do(RoleModerator, MethodSession).Code)
+	require.Equal(t, http.StatusOK, do(RoleAdmin, MethodAPIKey).Code)
+}
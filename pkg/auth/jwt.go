@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinSecretSize - минимальная длина секрета HMAC в байтах
+const MinSecretSize = 32
+
+// DefaultTokenTTL - срок действия выданного токена
+const DefaultTokenTTL = 24 * time.Hour
+
+// Claims - содержимое JWT: sub - ID пользователя, role - его роль
+type Claims struct {
+	Role Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Signer выпускает и проверяет JWT, подписанные HMAC-SHA256
+type Signer struct {
+	secret []byte
+	TTL    time.Duration
+	Issuer string
+}
+
+// NewSigner создает Signer; секрет должен быть не короче MinSecretSize
+func NewSigner(secret string) (*Signer, error) {
+	if len(secret) < MinSecretSize {
+		return nil, errors.New("jwt secret must be at least 32 bytes")
+	}
+	return &Signer{secret: []byte(secret), TTL: DefaultTokenTTL, Issuer: "gonews"}, nil
+}
+
+// Issue выпускает токен для subject с ролью role
+func (s *Signer) Issue(subject string, role Role) (string, error) {
+	if !role.Valid() {
+		return "", errors.New("unknown role " + string(role))
+	}
+	now := time.Now()
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    s.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.TTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// Verify проверяет подпись, срок действия и роль токена
+func (s *Signer) Verify(token string) (Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return s.secret, nil
+	},
+		// Алгоритм фиксирован, иначе токен с alg=none или чужим алгоритмом мог бы пройти
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" || !claims.Role.Valid() {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: claims.Subject, Role: claims.Role, Method: MethodJWT}, nil
+}
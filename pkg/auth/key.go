@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Префиксы отличают API ключи и сессии шлюза от других секретов, например в логах
+const (
+	KeyPrefix     = "gnk_"
+	SessionPrefix = "gns_"
+)
+
+// KeyLookup находит действующий API ключ или сессию по хэшу. Для неизвестного,
+// отозванного или истекшего значения возвращается ErrInvalidCredentials.
+type KeyLookup interface {
+	LookupKey(ctx context.Context, hash string) (Principal, error)
+}
+
+// GenerateKey создает случайный API ключ. Клиент получает его один раз, в
+// хранилище записывается только HashKey.
+func GenerateKey() (string, error) { return random(KeyPrefix) }
+
+// GenerateSession создает токен сессии пользователя; хранится, как и ключ, HashKey
+func GenerateSession() (string, error) { return random(SessionPrefix) }
+
+func random(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashKey - хэш ключа для хранения. Ключи случайные и длинные, поэтому
+// медленный хэш паролей не нужен и поиск по хэшу остается быстрым.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"news/pkg/apierror"
+)
+
+// Доверенные заголовки, которые шлюз передает внутренним сервисам
+const (
+	HeaderSubject = "X-Auth-Subject"
+	HeaderRole    = "X-Auth-Role"
+	HeaderMethod  = "X-Auth-Method"
+	HeaderName    = "X-Auth-Name" // Principal.Name, экранированное как сегмент пути URL
+
+	// HeaderSignature - HMAC-SHA256 остальных заголовков X-Auth-* на внутреннем секрете
+	HeaderSignature = "X-Auth-Signature"
+
+	// HeaderAPIKey - API ключ клиента, альтернатива Authorization: Bearer
+	HeaderAPIKey = "X-API-Key"
+)
+
+// Transport передает клиента из контекста в доверенных заголовках, подписанных
+// внутренним секретом. Заголовки, пришедшие от клиента (например, через прокси
+// WebSocket), и его учетные данные внутренним сервисам не передаются.
+func Transport(base http.RoundTripper, secret string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		for _, h := range []string{HeaderSubject, HeaderRole, HeaderMethod, HeaderName, HeaderSignature, HeaderAPIKey, "Authorization"} {
+			r.Header.Del(h)
+		}
+		if p, ok := FromContext(r.Context()); ok {
+			SetTrusted(r.Header, p, secret)
+		}
+		return base.RoundTrip(r)
+	})
+}
+
+// SetTrusted записывает клиента p в доверенные заголовки h и подписывает их
+func SetTrusted(h http.Header, p Principal, secret string) {
+	if p.Subject != "" {
+		h.Set(HeaderSubject, p.Subject)
+	}
+	if p.Role != "" {
+		h.Set(HeaderRole, string(p.Role))
+	}
+	if p.Name != "" {
+		h.Set(HeaderName, url.PathEscape(p.Name))
+	}
+	h.Set(HeaderMethod, p.Method)
+	h.Set(HeaderSignature, trustedSignature(h, secret))
+}
+
+// trustedSignature - подпись доверенных заголовков h
+func trustedSignature(h http.Header, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, name := range []string{HeaderSubject, HeaderRole, HeaderMethod, HeaderName} {
+		// Значения заголовков не содержат нулевых байтов, поэтому разделитель однозначен
+		io.WriteString(mac, h.Get(name))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckSecret проверяет внутренний секрет в настройках сервиса: он обязателен и
+// не короче MinSecretSize
+func CheckSecret(path, secret string) error {
+	if len(secret) < MinSecretSize {
+		return fmt.Errorf("%s: must be at least %d bytes", path, MinSecretSize)
+	}
+	return nil
+}
+
+type roundTripper func(r *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// Trusted - middleware внутреннего сервиса: клиент берется из заголовков шлюза,
+// подписанных секретом secret. Запрос без них считается анонимным, а с неверной
+// подписью или при пустом секрете отклоняется с 401: заголовки мог выставить
+// кто угодно, кто обратился к сервису напрямую.
+func Trusted(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := Principal{Method: MethodAnonymous}
+			if m := r.Header.Get(HeaderMethod); m != "" {
+				sig := r.Header.Get(HeaderSignature)
+				if secret == "" || !hmac.Equal([]byte(sig), []byte(trustedSignature(r.Header, secret))) {
+					unauthorized(w, r, "invalid gateway signature")
+					return
+				}
+				p = Principal{Subject: r.Header.Get(HeaderSubject), Role: Role(r.Header.Get(HeaderRole)), Method: m}
+				p.Name, _ = url.PathUnescape(r.Header.Get(HeaderName))
+			}
+			if !p.Role.Valid() {
+				p.Role = ""
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// Require - middleware операции внутреннего сервиса поверх Trusted: пропускает
+// клиента с ролью не ниже min. Шлюз проверяет роль по своей спецификации, а
+// сервис повторяет проверку, чтобы его операции не зависели от маршрутов шлюза.
+// Без подписанных заголовков шлюза - 401, при недостаточной роли - 403.
+func Require(min Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := FromContext(r.Context())
+			// Предварительный запрос CORS приходит без учетных данных
+			if r.Method != http.MethodOptions && !p.Role.Allows(min) {
+				if p.Method == "" || p.Method == MethodAnonymous {
+					unauthorized(w, r, "authentication required")
+					return
+				}
+				apierror.Write(w, r, apierror.Forbidden("role "+string(min)+" required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
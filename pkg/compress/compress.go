@@ -0,0 +1,246 @@
+// Package compress сжимает ответы HTTP (zstd или gzip) по заголовку Accept-Encoding.
+// Потоковые ответы (SSE, WebSocket), короткие тела и уже сжатые данные не сжимаются.
+package compress
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MinSize - тела короче этого размера передаются без сжатия
+const MinSize = 1024
+
+// Кодировки в порядке предпочтения сервера
+var encodings = []string{"zstd", "gzip"}
+
+// Типы содержимого, которые имеет смысл сжимать
+var compressible = []string{"application/json", "text/", "application/xml", "application/rss+xml", "application/atom+xml", "application/javascript"}
+
+var (
+	gzipPool = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}
+	zstdPool = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// Middleware сжимает ответы обработчика next
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &writer{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Negotiate выбирает кодировку из Accept-Encoding с учетом q-значений.
+// Пустая строка означает, что ответ передается без сжатия.
+func Negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range encodings {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+// writer накапливает начало тела, пока не станет ясно, нужно ли сжатие
+type writer struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+
+	statusSet   bool // обработчик вызвал WriteHeader
+	wroteHeader bool // заголовок записан клиенту
+	decided     bool
+	buf         []byte
+	enc         io.WriteCloser
+}
+
+func (cw *writer) WriteHeader(code int) {
+	if code < 200 {
+		// Информационные ответы не влияют на тело
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.decided || cw.statusSet {
+		return
+	}
+	cw.status, cw.statusSet = code, true
+	// Ответы без тела и потоковые ответы передаются сразу
+	if !cw.shouldCompress() {
+		cw.passthrough()
+	}
+}
+
+func (cw *writer) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if !cw.shouldCompress() {
+			cw.passthrough()
+			return cw.ResponseWriter.Write(b)
+		}
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < MinSize {
+			return len(b), nil
+		}
+		if err := cw.start(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// shouldCompress решает по статусу и заголовкам, выставленным обработчиком
+func (cw *writer) shouldCompress() bool {
+	h := cw.Header()
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if strings.HasPrefix(ct, "text/event-stream") {
+		return false
+	}
+	if ct == "" {
+		// Тип определит http.DetectContentType; JSON и текст распознаются как text/plain
+		return true
+	}
+	for _, c := range compressible {
+		if strings.HasPrefix(ct, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// passthrough отправляет ответ без сжатия
+func (cw *writer) passthrough() {
+	cw.decided = true
+	cw.writeHeader()
+	if len(cw.buf) > 0 {
+		cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+	}
+}
+
+// start включает сжатие и записывает накопленное начало тела
+func (cw *writer) start() error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	// Сжатое представление отличается побайтно, поэтому сильный валидатор становится слабым
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	cw.writeHeader()
+
+	switch cw.encoding {
+	case "zstd":
+		zw := zstdPool.Get().(*zstd.Encoder)
+		zw.Reset(cw.ResponseWriter)
+		cw.enc = zw
+	default:
+		gw := gzipPool.Get().(*gzip.Writer)
+		gw.Reset(cw.ResponseWriter)
+		cw.enc = gw
+	}
+	_, err := cw.enc.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+func (cw *writer) writeHeader() {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+}
+
+// Flush отправляет накопленные данные клиенту
+func (cw *writer) Flush() {
+	if !cw.decided {
+		// Обработчик хочет отдать данные сразу: ждать MinSize нельзя
+		if cw.shouldCompress() && len(cw.buf) > 0 {
+			cw.start()
+		} else {
+			cw.passthrough()
+		}
+	}
+	switch e := cw.enc.(type) {
+	case *gzip.Writer:
+		e.Flush()
+	case *zstd.Encoder:
+		e.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close завершает сжатый поток и возвращает кодировщик в пул
+func (cw *writer) Close() error {
+	if !cw.decided {
+		// Тело короче MinSize
+		cw.passthrough()
+		return nil
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	switch e := cw.enc.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(e)
+	case *zstd.Encoder:
+		zstdPool.Put(e)
+	}
+	cw.enc = nil
+	return err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (cw *writer) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
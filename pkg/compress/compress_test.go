@@ -0,0 +1,111 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	require.Equal(t, "", Negotiate(""))
+	require.Equal(t, "gzip", Negotiate("gzip, deflate"))
+	require.Equal(t, "zstd", Negotiate("gzip, deflate, br, zstd"))
+	require.Equal(t, "gzip", Negotiate("zstd;q=0.5, gzip"))
+	require.Equal(t, "", Negotiate("gzip;q=0, identity"))
+	require.Equal(t, "zstd", Negotiate("*"))
+}
+
+func serve(t *testing.T, h http.HandlerFunc, acceptEncoding string) *http.Response {
+	r := httptest.NewRequest(http.MethodGet, "/news", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	Middleware(h).ServeHTTP(w, r)
+	return w.Result()
+}
+
+var large = `{"news": "` + strings.Repeat("Длинный текст новости. ", 200) + `"}`
+
+func jsonHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		io.WriteString(w, body)
+	}
+}
+
+func TestMiddlewareGzip(t *testing.T) {
+	resp := serve(t, jsonHandler(large), "gzip")
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	require.Equal(t, `W/"abc"`, resp.Header.Get("ETag"))
+
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+}
+
+func TestMiddlewareZstd(t *testing.T) {
+	resp := serve(t, jsonHandler(large), "gzip, zstd")
+	require.Equal(t, "zstd", resp.Header.Get("Content-Encoding"))
+
+	zr, err := zstd.NewReader(resp.Body)
+	require.NoError(t, err)
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+}
+
+func TestMiddlewareSkips(t *testing.T) {
+	// Короткое тело
+	resp := serve(t, jsonHandler(`{"id": 1}`), "gzip")
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	require.Equal(t, `"abc"`, resp.Header.Get("ETag"))
+
+	// Клиент не поддерживает сжатие
+	resp = serve(t, jsonHandler(large), "")
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	// Поток событий
+	resp = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, large)
+	}, "gzip")
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	// 304 без тела
+	resp = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusNotModified)
+	}, "gzip")
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	// Изображения уже сжаты
+	resp = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(bytes.Repeat([]byte{0}, 4096))
+	}, "gzip")
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+func TestMiddlewareKeepsStatus(t *testing.T) {
+	resp := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, large)
+	}, "gzip")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+}
@@ -0,0 +1,282 @@
+// Package config загружает настройки сервиса из нескольких источников.
+// Приоритет по возрастанию: значения по умолчанию, файл JSON или YAML,
+// переменные окружения, флаги командной строки.
+//
+// Имена берутся из тегов json структуры настроек. Для поля tracing.exporter
+// сервиса с префиксом NEWS это ключ "tracing": {"exporter": ...} в файле,
+// переменная NEWS_TRACING_EXPORTER и флаг -tracing.exporter. Через окружение и
+// флаги задаются поля простых типов и списки строк (через запятую); словари и
+// списки структур - только в файле.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options - откуда читать настройки
+type Options struct {
+	Name      string   // имя сервиса для справки по флагам
+	EnvPrefix string   // префикс переменных окружения, например NEWS
+	File      string   // файл по умолчанию; если его нет, он пропускается
+	Args      []string // аргументы командной строки без имени программы
+}
+
+// Validator проверяет настройки после загрузки
+type Validator interface {
+	Validate() error
+}
+
+// Load заполняет dst, в котором уже записаны значения по умолчанию. Путь к файлу
+// задается флагом -config или переменной <PREFIX>_CONFIG; явно указанный файл
+// обязан существовать.
+func Load(dst any, opts Options) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: %T is not a pointer to struct", dst)
+	}
+	var fields []field
+	collect(v.Elem(), "", &fields)
+
+	// Флаги разбираются первыми, чтобы узнать путь к файлу, но применяются последними
+	fs := flag.NewFlagSet(opts.Name, flag.ContinueOnError)
+	file := fs.String("config", "", "файл настроек JSON или YAML (env "+envName(opts.EnvPrefix, "config")+")")
+	type flagValue struct {
+		field field
+		value string
+	}
+	var flagValues []flagValue
+	for _, f := range fields {
+		f := f
+		fs.Func(f.path, "env "+envName(opts.EnvPrefix, f.path), func(s string) error {
+			flagValues = append(flagValues, flagValue{f, s})
+			return nil
+		})
+	}
+	if err := fs.Parse(opts.Args); err != nil {
+		return err
+	}
+
+	if path, required := filePath(opts, *file); path != "" {
+		if err := readFile(path, dst, required); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		name := envName(opts.EnvPrefix, f.path)
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := set(f.value, s); err != nil {
+			return fmt.Errorf("config: env %s: %w", name, err)
+		}
+	}
+	for _, fv := range flagValues {
+		if err := set(fv.field.value, fv.value); err != nil {
+			return fmt.Errorf("config: flag -%s: %w", fv.field.path, err)
+		}
+	}
+
+	if val, ok := dst.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return &ValidationError{Errs: unjoin(err)}
+		}
+	}
+	return nil
+}
+
+// ValidationError - список нарушений, найденных Validate
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return "config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error { return e.Errs }
+
+// unjoin раскрывает ошибку errors.Join в список
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// MustLoad загружает настройки и завершает программу при ошибке. На флаг -h
+// выводится справка, и программа завершается успешно.
+func MustLoad(dst any, opts Options) {
+	err := Load(dst, opts)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// filePath выбирает файл настроек: флаг -config, затем <PREFIX>_CONFIG, затем
+// файл по умолчанию, который может отсутствовать
+func filePath(opts Options, flagValue string) (path string, required bool) {
+	if flagValue != "" {
+		return flagValue, true
+	}
+	if path := os.Getenv(envName(opts.EnvPrefix, "config")); path != "" {
+		return path, true
+	}
+	return opts.File, false
+}
+
+// readFile читает файл JSON или YAML поверх текущих значений dst. Неизвестные
+// ключи считаются ошибкой, чтобы опечатка в имени не оставалась незамеченной.
+func readFile(path string, dst any, required bool) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("config: %s: unsupported format, want .json, .yaml or .yml", path)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	if ext != ".json" {
+		// YAML приводится к JSON, чтобы теги json оставались единственным описанием имен
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// field - настройка, которую можно задать строкой
+type field struct {
+	path  string // путь из тегов json через точку
+	value reflect.Value
+}
+
+// collect находит поля простых типов. Встроенные структуры без тега
+// раскрываются так же, как их раскрывает encoding/json.
+func collect(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			collect(fv, prefix, out)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		switch {
+		case fv.Kind() == reflect.Struct:
+			collect(fv, name, out)
+		case settable(fv.Type()):
+			*out = append(*out, field{path: name, value: fv})
+		}
+	}
+}
+
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// set записывает в v значение из строки
+func set(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a non-negative integer", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	}
+	return nil
+}
+
+// envName - имя переменной окружения для пути: NEWS + http.proxy = NEWS_HTTP_PROXY
+func envName(prefix, path string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
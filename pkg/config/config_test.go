@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type inner struct {
+	Exporter string  `json:"exporter"`
+	Ratio    float64 `json:"ratio"`
+}
+
+type embedded struct {
+	URLs    []string `json:"rss"`
+	Workers int      `json:"workers"`
+}
+
+type testConfig struct {
+	embedded
+	Addr    string         `json:"addr"`
+	Debug   bool           `json:"debug"`
+	Tracing inner          `json:"tracing"`
+	Extra   map[string]int `json:"extra"`
+}
+
+func (c *testConfig) Validate() error {
+	return errors.Join(Addr("addr", c.Addr), Positive("workers", c.Workers))
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+addr: ":9000"
+workers: 2
+rss: [a, b]
+tracing:
+  exporter: file
+  ratio: 0.5
+extra: {x: 1}
+`)
+	t.Setenv("TEST_WORKERS", "3")
+	t.Setenv("TEST_TRACING_EXPORTER", "otlp")
+
+	cfg := testConfig{Addr: ":80", embedded: embedded{Workers: 1}}
+	err := Load(&cfg, Options{EnvPrefix: "TEST", Args: []string{"-config", path, "-workers", "4", "-rss", "c, d"}})
+	require.NoError(t, err)
+
+	require.Equal(t, ":9000", cfg.Addr)            // файл
+	require.Equal(t, 4, cfg.Workers)               // флаг поверх окружения
+	require.Equal(t, "otlp", cfg.Tracing.Exporter) // окружение поверх файла
+	require.Equal(t, 0.5, cfg.Tracing.Ratio)
+	require.Equal(t, []string{"c", "d"}, cfg.URLs)
+	require.Equal(t, map[string]int{"x": 1}, cfg.Extra)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	// Файл по умолчанию может отсутствовать
+	cfg := testConfig{Addr: ":80", embedded: embedded{Workers: 1}}
+	require.NoError(t, Load(&cfg, Options{File: filepath.Join(t.TempDir(), "missing.json")}))
+	require.Equal(t, ":80", cfg.Addr)
+
+	// Явно указанный - нет
+	t.Setenv("TEST_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, Load(&cfg, Options{EnvPrefix: "TEST"}))
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  string
+		want string
+	}{
+		{name: "неизвестный ключ", file: `{"adr": ":1"}`, want: `unknown field "adr"`},
+		{name: "формат", args: []string{"-config", "app.toml"}, want: "unsupported format"},
+		{name: "окружение", env: "many", want: `env TEST_WORKERS: "many" is not an integer`},
+		{name: "флаг", args: []string{"-debug", "maybe"}, want: `flag -debug: "maybe" is not a boolean`},
+		{name: "проверка", args: []string{"-addr", "localhost", "-workers", "0"}, want: `addr: "localhost" is not a listen address`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "app.json", tt.file)}, args...)
+			}
+			if tt.env != "" {
+				t.Setenv("TEST_WORKERS", tt.env)
+			}
+			cfg := testConfig{Addr: ":80", embedded: embedded{Workers: 1}}
+			err := Load(&cfg, Options{EnvPrefix: "TEST", Args: args})
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	// Все нарушения перечисляются сразу
+	var cfg testConfig
+	err := Load(&cfg, Options{})
+	require.EqualError(t, err, `config: addr: "" is not a listen address like :8080; workers must be positive, got 0`)
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Errs, 2)
+}
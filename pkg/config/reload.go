@@ -0,0 +1,179 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"news/pkg/apierror"
+)
+
+// DefaultWatchInterval - как часто проверяется изменение файла настроек
+const DefaultWatchInterval = 2 * time.Second
+
+// Источники перезагрузки
+const (
+	TriggerStartup = "startup"
+	TriggerSignal  = "signal" // SIGHUP
+	TriggerFile    = "file"   // файл настроек изменился
+	TriggerAPI     = "api"    // POST /admin/reload
+)
+
+// ReloadStatus - результат последней загрузки настроек
+type ReloadStatus struct {
+	Status  string    `json:"status"` // ok или failed
+	Trigger string    `json:"trigger"`
+	File    string    `json:"file,omitempty"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Reloader перечитывает настройки по SIGHUP, при изменении файла и по запросу
+// к Handler. Новые настройки загружаются из тех же источников, что и при
+// запуске, проверяются и передаются в apply; при ошибке сервис продолжает
+// работать со старыми.
+type Reloader struct {
+	Interval time.Duration // период проверки файла; 0 - DefaultWatchInterval
+
+	file    string
+	version version // состояние файла при создании, от него отсчитываются изменения
+	reload  func() error
+
+	mu   sync.Mutex
+	last ReloadStatus
+}
+
+// NewReloader создает Reloader. defaults возвращает значения по умолчанию,
+// apply применяет то, что можно изменить на лету.
+func NewReloader[T any](opts Options, defaults func() T, apply func(*T) error) *Reloader {
+	file, _ := filePath(opts, flagConfig(opts.Args))
+	return &Reloader{
+		file:    file,
+		version: fileVersion(file),
+		reload: func() error {
+			cfg := defaults()
+			if err := Load(&cfg, opts); err != nil {
+				return err
+			}
+			return apply(&cfg)
+		},
+		last: ReloadStatus{Status: "ok", Trigger: TriggerStartup, File: file, Time: time.Now()},
+	}
+}
+
+// flagConfig находит значение флага -config в аргументах
+func flagConfig(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// Reload перечитывает настройки и запоминает результат
+func (r *Reloader) Reload(trigger string) ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := ReloadStatus{Status: "ok", Trigger: trigger, File: r.file, Time: time.Now()}
+	if err := r.reload(); err != nil {
+		st.Status, st.Error = "failed", err.Error()
+		log.Printf("Config reload (%s) failed: %v", trigger, err)
+	} else {
+		log.Printf("Config reloaded (%s)", trigger)
+	}
+	r.last = st
+	return st
+}
+
+// Last возвращает результат последней загрузки
+func (r *Reloader) Last() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Run следит за SIGHUP и изменениями файла до отмены ctx
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	version := r.version
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload(TriggerSignal)
+		case <-ticker.C:
+			// Редакторы часто заменяют файл целиком, поэтому сравниваются время и размер
+			if v := fileVersion(r.file); v != version {
+				version = v
+				r.Reload(TriggerFile)
+			}
+		}
+	}
+}
+
+// version - признак изменения файла
+type version struct {
+	modTime time.Time
+	size    int64
+}
+
+func fileVersion(path string) version {
+	if path == "" {
+		return version{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return version{}
+	}
+	return version{fi.ModTime(), fi.Size()}
+}
+
+// Handler - эндпоинт /admin/reload: GET возвращает результат последней загрузки,
+// POST перечитывает настройки. Неудачная загрузка - 422.
+func (r *Reloader) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var st ReloadStatus
+		switch req.Method {
+		case http.MethodGet:
+			st = r.Last()
+		case http.MethodPost:
+			st = r.Reload(TriggerAPI)
+		default:
+			apierror.Write(w, req, apierror.MethodNotAllowed())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if st.Status != "ok" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(st)
+	})
+}
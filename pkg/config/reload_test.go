@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader(t *testing.T) {
+	path := writeFile(t, "app.json", `{"workers": 2}`)
+	defaults := func() testConfig { return testConfig{Addr: ":80", embedded: embedded{Workers: 1}} }
+
+	var workers atomic.Int64
+	r := NewReloader(Options{Args: []string{"-config=" + path}}, defaults, func(c *testConfig) error {
+		workers.Store(int64(c.Workers))
+		return nil
+	})
+	r.Interval = 10 * time.Millisecond
+	require.Equal(t, TriggerStartup, r.Last().Trigger)
+	require.Equal(t, path, r.Last().File)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// Изменение файла подхватывается само; mtime сдвигается явно, чтобы не зависеть
+	// от точности времени файловой системы
+	require.NoError(t, os.WriteFile(path, []byte(`{"workers": 5}`), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	require.Eventually(t, func() bool { return workers.Load() == 5 }, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, TriggerFile, r.Last().Trigger)
+
+	// Некорректные настройки не применяются, ошибка видна в /admin/reload
+	require.NoError(t, os.WriteFile(path, []byte(`{"workers": 0}`), 0o600))
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var st ReloadStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	require.Equal(t, "failed", st.Status)
+	require.Equal(t, TriggerAPI, st.Trigger)
+	require.Contains(t, st.Error, "workers must be positive")
+	require.Equal(t, int64(5), workers.Load())
+
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"workers": 7}`), 0o600))
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, int64(7), workers.Load())
+}
+
+func TestFlagConfig(t *testing.T) {
+	require.Equal(t, "a.json", flagConfig([]string{"-addr", ":1", "-config", "a.json"}))
+	require.Equal(t, "b.yaml", flagConfig([]string{"--config=b.yaml"}))
+	require.Equal(t, "", flagConfig([]string{"-configx", "c.json"}))
+}
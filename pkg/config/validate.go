@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Проверки для Validate. Каждая возвращает nil или ошибку с путем настройки;
+// несколько проверок объединяются через errors.Join.
+
+// Required - строка не пуста
+func Required(path, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", path)
+	}
+	return nil
+}
+
+// Addr - адрес для прослушивания вида host:port или :port
+func Addr(path, value string) error {
+	if _, port, err := net.SplitHostPort(value); err != nil || port == "" {
+		return fmt.Errorf("%s: %q is not a listen address like :8080", path, value)
+	}
+	return nil
+}
+
+// URL - абсолютный адрес http или https
+func URL(path, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute http(s) URL", path, value)
+	}
+	return nil
+}
+
+// Positive - число больше нуля
+func Positive[T int | int64 | float64](path string, value T) error {
+	if value <= 0 {
+		return fmt.Errorf("%s must be positive, got %v", path, value)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package fields реализует параметр fields=: клиент перечисляет через запятую
+// нужные поля JSON объекта, остальные не передаются.
+package fields
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Set - поля, которые можно запросить
+type Set map[string]bool
+
+// NewSet создает набор допустимых полей
+func NewSet(names ...string) Set {
+	s := make(Set, len(names))
+	for _, n := range names {
+		s[n] = true
+	}
+	return s
+}
+
+// Parse разбирает список полей через запятую. Пустая строка означает все поля (nil).
+func (s Set) Parse(param string) ([]string, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(param, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !s[f] {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Project оставляет в JSON представлении v только указанные поля
+func Project(v any, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	m := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if raw, ok := all[f]; ok {
+			m[f] = raw
+		} else {
+			m[f] = json.RawMessage("null")
+		}
+	}
+	return m, nil
+}
+
+// ProjectAll применяет Project к каждому элементу
+func ProjectAll[T any](items []T, fields []string) ([]map[string]json.RawMessage, error) {
+	res := make([]map[string]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		m, err := Project(item, fields)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
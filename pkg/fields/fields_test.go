@@ -0,0 +1,41 @@
+package fields
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Flag    bool   `json:"flag,omitempty"`
+}
+
+func TestParse(t *testing.T) {
+	s := NewSet("id", "title", "content", "flag")
+
+	f, err := s.Parse("")
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	f, err = s.Parse(" id ,title,,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "title"}, f)
+
+	_, err = s.Parse("id,secret")
+	require.EqualError(t, err, `unknown field "secret"`)
+}
+
+func TestProjectAll(t *testing.T) {
+	items := []item{{ID: 1, Title: "a", Content: "long"}, {ID: 2, Title: "b", Content: "long", Flag: true}}
+	res, err := ProjectAll(items, []string{"id", "flag"})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(res)
+	require.NoError(t, err)
+	// Пропущенное из-за omitempty поле передается как null, чтобы набор полей был постоянным
+	require.JSONEq(t, `[{"id": 1, "flag": null}, {"id": 2, "flag": true}]`, string(b))
+}
@@ -0,0 +1,167 @@
+// Package health реализует эндпоинты /healthz (процесс жив) и /readyz (зависимости
+// доступны) и сводный отчет о состоянии сервисов.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Состояние проверки
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// DefaultTimeout - время на одну проверку по умолчанию
+const DefaultTimeout = 2 * time.Second
+
+// Result - результат проверки одной зависимости
+type Result struct {
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	LatencyMS float64  `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+	Checks    []Result `json:"checks,omitempty"` // проверки удаленного сервиса
+}
+
+// Report - итог всех проверок
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Check проверяет зависимость и возвращает ошибку, если она недоступна
+type Check func(ctx context.Context) error
+
+// check - проверка, которая может вернуть вложенные результаты
+type check struct {
+	name string
+	run  func(ctx context.Context) ([]Result, error)
+}
+
+// Checker выполняет проверки зависимостей параллельно, каждую со своим таймаутом
+type Checker struct {
+	Timeout time.Duration
+	checks  []check
+}
+
+// New создает пустой набор проверок
+func New() *Checker {
+	return &Checker{Timeout: DefaultTimeout}
+}
+
+// Add добавляет проверку зависимости
+func (c *Checker) Add(name string, fn Check) *Checker {
+	c.checks = append(c.checks, check{name: name, run: func(ctx context.Context) ([]Result, error) {
+		return nil, fn(ctx)
+	}})
+	return c
+}
+
+// AddRemote добавляет проверку другого сервиса по адресу его /healthz или /readyz.
+// Проверки из отчета сервиса включаются во вложенные результаты.
+func (c *Checker) AddRemote(name string, client *http.Client, url string) *Checker {
+	c.checks = append(c.checks, check{name: name, run: func(ctx context.Context) ([]Result, error) {
+		return fetch(ctx, client, url)
+	}})
+	return c
+}
+
+// Run выполняет все проверки
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make([]Result, len(c.checks))}
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	nested, err := ch.run(ctx)
+	res := Result{
+		Name:      ch.name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Checks:    nested,
+	}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	return res
+}
+
+// Handler отвечает отчетом проверок: 200, если все зависимости доступны, иначе 503
+func (c *Checker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		write(w, status, report)
+	})
+}
+
+// StatusHandler отвечает отчетом проверок всегда с кодом 200: состояние
+// зависимостей передается в теле и не означает неготовность самого сервиса
+func (c *Checker) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, c.Run(r.Context()))
+	})
+}
+
+// Live - обработчик /healthz: процесс отвечает на запросы
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+func write(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// fetch запрашивает отчет удаленного сервиса. Сервис считается доступным, если
+// ответил 200; тело без отчета (например, от старой версии) не является ошибкой.
+func fetch(ctx context.Context, client *http.Client, url string) ([]Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var report Report
+	json.NewDecoder(resp.Body).Decode(&report)
+	if resp.StatusCode != http.StatusOK {
+		return report.Checks, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return report.Checks, nil
+}
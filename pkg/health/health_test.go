@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	c := New().
+		Add("db", func(ctx context.Context) error { return nil }).
+		Add("cache", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report := c.Run(context.Background())
+	require.Equal(t, StatusFail, report.Status)
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, "db", report.Checks[0].Name)
+	require.Equal(t, StatusOK, report.Checks[0].Status)
+	require.Equal(t, StatusFail, report.Checks[1].Status)
+	require.Equal(t, "connection refused", report.Checks[1].Error)
+
+	w := httptest.NewRecorder()
+	c.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	c.StatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := New().Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.Timeout = 10 * time.Millisecond
+
+	report := c.Run(context.Background())
+	require.Equal(t, StatusFail, report.Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestRemote(t *testing.T) {
+	backend := New().Add("postgres", func(ctx context.Context) error { return errors.New("down") })
+	srv := httptest.NewServer(backend.Handler())
+	defer srv.Close()
+	live := httptest.NewServer(Live())
+	defer live.Close()
+
+	c := New().
+		AddRemote("news", http.DefaultClient, srv.URL).
+		AddRemote("censor", http.DefaultClient, live.URL).
+		AddRemote("comments", http.DefaultClient, "http://127.0.0.1:1")
+
+	w := httptest.NewRecorder()
+	c.StatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var report Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+
+	require.Equal(t, StatusFail, report.Status)
+	news := report.Checks[0]
+	require.Equal(t, StatusFail, news.Status)
+	require.Equal(t, "status 503", news.Error)
+	require.Len(t, news.Checks, 1)
+	require.Equal(t, "postgres", news.Checks[0].Name)
+	require.Equal(t, "down", news.Checks[0].Error)
+
+	require.Equal(t, StatusOK, report.Checks[1].Status)
+	require.Equal(t, StatusFail, report.Checks[2].Status)
+}
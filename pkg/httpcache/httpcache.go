@@ -0,0 +1,96 @@
+// Package httpcache - условные GET запросы: сильные ETag, If-None-Match и 304.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ETag строит сильный валидатор из частей, однозначно определяющих ответ
+func ETag(parts ...any) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%v\x00", p)
+	}
+	return quote(h.Sum(nil))
+}
+
+// ETagBytes строит валидатор по телу ответа
+func ETagBytes(body []byte) string {
+	sum := sha256.Sum256(body)
+	return quote(sum[:])
+}
+
+func quote(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// Matches проверяет, совпадает ли etag с одним из значений If-None-Match.
+// Для GET используется слабое сравнение: префикс W/ игнорируется.
+func Matches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || etag == "" {
+		return false
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Check выставляет ETag и Cache-Control и, если клиент уже имеет эту версию,
+// отвечает 304 и возвращает true
+func Check(w http.ResponseWriter, r *http.Request, etag, cacheControl string) bool {
+	w.Header().Set("ETag", etag)
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	if Matches(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// WriteJSON отправляет data в JSON с ETag по содержимому ответа
+func WriteJSON(w http.ResponseWriter, r *http.Request, data any, cacheControl string) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+	if Check(w, r, ETagBytes(body), cacheControl) {
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	return err
+}
+
+// Validators - заголовки, которые прокси передает между клиентом и сервисом
+var Validators = []string{"ETag", "Cache-Control", "Last-Modified"}
+
+// CopyValidators копирует валидаторы ответа сервиса в ответ клиенту
+func CopyValidators(dst http.Header, src http.Header) {
+	for _, h := range Validators {
+		if v := src.Get(h); v != "" {
+			dst.Set(h, v)
+		}
+	}
+}
+
+// ForwardConditional копирует условные заголовки запроса клиента в запрос к сервису
+func ForwardConditional(dst *http.Request, src *http.Request) {
+	for _, h := range []string{"If-None-Match", "If-Modified-Since"} {
+		if v := src.Header.Get(h); v != "" {
+			dst.Header.Set(h, v)
+		}
+	}
+}
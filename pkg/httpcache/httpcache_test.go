@@ -0,0 +1,52 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag(t *testing.T) {
+	require.Equal(t, ETag("v1", 2, "title"), ETag("v1", 2, "title"))
+	require.NotEqual(t, ETag("v1", 2), ETag("v1", 3))
+	// Разделитель не дает частям склеиться
+	require.NotEqual(t, ETag("ab", "c"), ETag("a", "bc"))
+	require.Regexp(t, `^"[0-9a-f]{24}"$`, ETagBytes([]byte("{}")))
+}
+
+func TestMatches(t *testing.T) {
+	etag := ETag("x")
+	r := httptest.NewRequest(http.MethodGet, "/news", nil)
+	require.False(t, Matches(r, etag))
+
+	r.Header.Set("If-None-Match", `"other", W/`+etag)
+	require.True(t, Matches(r, etag))
+
+	r.Header.Set("If-None-Match", "*")
+	require.True(t, Matches(r, etag))
+
+	r.Header.Set("If-None-Match", `"other"`)
+	require.False(t, Matches(r, etag))
+}
+
+func TestWriteJSON(t *testing.T) {
+	data := map[string]int{"id": 1}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/news/1", nil)
+	require.NoError(t, WriteJSON(w, r, data, "public, max-age=60"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.JSONEq(t, `{"id": 1}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+	require.NoError(t, WriteJSON(w, r, data, "public, max-age=60"))
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, etag, w.Header().Get("ETag"))
+}
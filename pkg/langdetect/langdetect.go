@@ -0,0 +1,139 @@
+// Package langdetect определяет язык текста по триграммным профилям
+// (метод Кавнара-Тренкла). Модель строится из встроенных образцов и не требует сети.
+package langdetect
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	profileSize = 300 // сколько самых частых триграмм хранить в профиле
+	minLetters  = 12  // текст короче этого считается неопределимым
+)
+
+// Unknown возвращается, если язык определить не удалось
+const Unknown = ""
+
+// profile - ранги триграмм языка
+type profile map[string]int
+
+var profiles = make(map[string]profile)
+
+// Скрипты языков: сначала по алфавиту отбираются кандидаты, затем сравниваются профили
+var scripts = map[string]*unicode.RangeTable{
+	"ru": unicode.Cyrillic,
+	"uk": unicode.Cyrillic,
+	"en": unicode.Latin,
+	"de": unicode.Latin,
+}
+
+func init() {
+	for lang, text := range samples {
+		profiles[lang] = buildProfile(text)
+	}
+}
+
+// Languages возвращает поддерживаемые языки
+func Languages() []string {
+	langs := make([]string, 0, len(profiles))
+	for l := range profiles {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Detect возвращает код языка (ru, uk, en, de) или Unknown
+func Detect(text string) string {
+	script, letters := dominantScript(text)
+	if letters < minLetters || script == nil {
+		return Unknown
+	}
+
+	doc := buildProfile(text)
+	best, bestDist := Unknown, -1
+	for _, lang := range Languages() {
+		if scripts[lang] != script {
+			continue
+		}
+		d := distance(doc, profiles[lang])
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = lang, d
+		}
+	}
+	return best
+}
+
+// dominantScript определяет преобладающий алфавит и число букв
+func dominantScript(text string) (*unicode.RangeTable, int) {
+	var cyr, lat int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyr++
+		case unicode.Is(unicode.Latin, r):
+			lat++
+		}
+	}
+	switch {
+	case cyr == 0 && lat == 0:
+		return nil, 0
+	case cyr >= lat:
+		return unicode.Cyrillic, cyr
+	default:
+		return unicode.Latin, lat
+	}
+}
+
+// buildProfile строит профиль: триграммы слов, дополненных пробелами, по убыванию частоты
+func buildProfile(text string) profile {
+	counts := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		runes := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			counts[string(runes[i:i+3])]++
+		}
+	}
+
+	grams := make([]string, 0, len(counts))
+	for g := range counts {
+		grams = append(grams, g)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > profileSize {
+		grams = grams[:profileSize]
+	}
+
+	p := make(profile, len(grams))
+	for rank, g := range grams {
+		p[g] = rank
+	}
+	return p
+}
+
+// distance - сумма смещений рангов триграмм документа относительно профиля языка
+func distance(doc, lang profile) int {
+	d := 0
+	for g, rank := range doc {
+		if r, ok := lang[g]; ok {
+			if r > rank {
+				d += r - rank
+			} else {
+				d += rank - r
+			}
+		} else {
+			d += profileSize
+		}
+	}
+	return d
+}
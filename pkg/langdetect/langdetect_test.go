@@ -0,0 +1,26 @@
+package langdetect_test
+
+import (
+	"testing"
+
+	"news/pkg/langdetect"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"Apple выпустила обновление iOS с исправлениями ошибок и новыми функциями":          "ru",
+		"Україна отримала нову партію обладнання для енергетичних підприємств":              "uk",
+		"NVIDIA announced quarterly earnings that beat analyst expectations":                "en",
+		"Die neue Grafikkarte ist schneller und verbraucht weniger Strom als der Vorgänger": "de",
+	}
+	for text, want := range cases {
+		require.Equal(t, want, langdetect.Detect(text), text)
+	}
+}
+
+func TestDetectUnknown(t *testing.T) {
+	require.Equal(t, langdetect.Unknown, langdetect.Detect("iOS 18"))
+	require.Equal(t, langdetect.Unknown, langdetect.Detect("12345 !!!"))
+}
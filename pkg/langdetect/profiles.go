@@ -0,0 +1,45 @@
+package langdetect
+
+// Обучающие тексты для построения триграммных профилей языков.
+// Тематика близка к новостным лентам, чтобы профили отражали нужную лексику.
+var samples = map[string]string{
+	"ru": `Компания представила новый процессор для настольных компьютеров и ноутбуков.
+По словам разработчиков, производительность выросла почти на треть, а энергопотребление снизилось.
+Продажи начнутся в следующем месяце, цены пока не объявлены. Эксперты считают, что это изменит рынок.
+Правительство утвердило план развития отрасли на ближайшие годы. В документе говорится о поддержке
+отечественных производителей, строительстве новых заводов и подготовке специалистов. Министерство
+сообщило, что первые результаты ожидаются уже в этом году. Пользователи смогут обновить приложение
+бесплатно, а владельцы старых устройств получат исправления безопасности. Исследователи из университета
+опубликовали работу о том, как искусственный интеллект помогает врачам ставить диагнозы быстрее и точнее.
+Новая версия операционной системы получила улучшенный интерфейс, поддержку видеокарт и быструю загрузку.`,
+
+	"uk": `Компанія представила новий процесор для настільних комп'ютерів і ноутбуків.
+За словами розробників, продуктивність зросла майже на третину, а енергоспоживання знизилося.
+Продажі розпочнуться наступного місяця, ціни поки що не оголошено. Експерти вважають, що це змінить ринок.
+Уряд затвердив план розвитку галузі на найближчі роки. У документі йдеться про підтримку вітчизняних
+виробників, будівництво нових заводів і підготовку фахівців. Міністерство повідомило, що перші результати
+очікуються вже цього року. Користувачі зможуть оновити застосунок безкоштовно, а власники старих пристроїв
+отримають виправлення безпеки. Дослідники з університету опублікували роботу про те, як штучний інтелект
+допомагає лікарям ставити діагнози швидше і точніше. Нова версія операційної системи отримала покращений
+інтерфейс, підтримку відеокарт і швидке завантаження.`,
+
+	"en": `The company unveiled a new processor for desktop computers and laptops.
+According to the developers, performance increased by almost a third while power consumption went down.
+Sales will start next month, and prices have not been announced yet. Experts believe this will change the market.
+The government approved a plan for the development of the industry over the coming years. The document
+describes support for domestic manufacturers, construction of new factories and training of specialists.
+The ministry said the first results are expected this year. Users will be able to update the application
+for free, and owners of older devices will receive security fixes. Researchers from the university published
+a paper about how artificial intelligence helps doctors make diagnoses faster and more accurately.
+The new version of the operating system received an improved interface, graphics card support and fast boot.`,
+
+	"de": `Das Unternehmen stellte einen neuen Prozessor für Desktop-Computer und Notebooks vor.
+Nach Angaben der Entwickler stieg die Leistung um fast ein Drittel, während der Energieverbrauch sank.
+Der Verkauf beginnt im nächsten Monat, die Preise wurden noch nicht bekannt gegeben. Experten glauben,
+dass dies den Markt verändern wird. Die Regierung hat einen Plan zur Entwicklung der Branche für die
+kommenden Jahre verabschiedet. Das Dokument beschreibt die Unterstützung einheimischer Hersteller,
+den Bau neuer Fabriken und die Ausbildung von Fachkräften. Das Ministerium teilte mit, dass die ersten
+Ergebnisse noch in diesem Jahr erwartet werden. Nutzer können die Anwendung kostenlos aktualisieren,
+und Besitzer älterer Geräte erhalten Sicherheitskorrekturen. Forscher der Universität veröffentlichten
+eine Arbeit darüber, wie künstliche Intelligenz Ärzten hilft, Diagnosen schneller und genauer zu stellen.`,
+}
@@ -0,0 +1,121 @@
+// Package metrics - метрики Prometheus, общие для всех сервисов: счетчики и
+// длительность HTTP запросов по маршрутам, а также метрики предметной области.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"news/pkg/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// OtherRoute - метка маршрута для путей, которых нет в таблице маршрутов.
+// Произвольные пути не попадают в метки, чтобы число рядов было ограничено.
+const OtherRoute = "other"
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Количество HTTP запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Длительность обработки HTTP запросов.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Метрики предметной области
+var (
+	// FeedFetchDuration - длительность загрузки и разбора RSS ленты
+	FeedFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rss_fetch_duration_seconds",
+		Help:    "Длительность загрузки и разбора RSS ленты.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"source"})
+
+	// FeedFetchErrors - ошибки загрузки ленты
+	FeedFetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rss_fetch_errors_total",
+		Help: "Ошибки загрузки и разбора RSS ленты.",
+	}, []string{"source"})
+
+	// PostsIngested - посты, сохраненные в БД
+	PostsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "posts_ingested_total",
+		Help: "Новые посты, сохраненные в БД.",
+	}, []string{"source"})
+
+	// PostsSkipped - элементы лент, не сохраненные в БД: reason = rejected
+	// (отклонены конвейером) или duplicate (пост уже есть)
+	PostsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "posts_skipped_total",
+		Help: "Элементы лент, не сохраненные в БД.",
+	}, []string{"source", "reason"})
+
+	// CensorVerdicts - решения цензора: accepted или rejected
+	CensorVerdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "censor_verdicts_total",
+		Help: "Решения сервиса цензуры.",
+	}, []string{"verdict"})
+
+	// UpstreamDuration - длительность запросов шлюза к сервисам
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Длительность запросов шлюза к внутренним сервисам.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method", "status"})
+)
+
+// Handler отдает метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware считает запросы и их длительность. route возвращает шаблон маршрута
+// по пути запроса, например /news/{id}; пустая строка заменяется на OtherRoute.
+func Middleware(route func(path string) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := middleware.NewStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			name := route(r.URL.Path)
+			if name == "" {
+				name = OtherRoute
+			}
+			status := strconv.Itoa(rec.Status())
+			requests.WithLabelValues(name, r.Method, status).Inc()
+			duration.WithLabelValues(name, r.Method, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// Transport измеряет длительность исходящих запросов. service возвращает имя
+// сервиса, к которому обращается запрос.
+func Transport(base http.RoundTripper, service func(r *http.Request) string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(r)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		UpstreamDuration.WithLabelValues(service(r), r.Method, status).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripper func(r *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
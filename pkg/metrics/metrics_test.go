@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	route := func(path string) string {
+		if strings.HasPrefix(path, "/news/") {
+			return "/news/{id}"
+		}
+		return ""
+	}
+	h := Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/news/2" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+
+	for _, path := range []string{"/news/1", "/news/3", "/news/2", "/random/path"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.Equal(t, 2.0, testutil.ToFloat64(requests.WithLabelValues("/news/{id}", "GET", "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues("/news/{id}", "GET", "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(OtherRoute, "GET", "200")))
+	require.Equal(t, 3, testutil.CollectAndCount(duration))
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport(nil, func(*http.Request) string { return "news" })}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:1")
+	require.Error(t, err)
+
+	out := gather(t)
+	require.Contains(t, out, `gateway_upstream_request_duration_seconds_count{method="GET",service="news",status="418"} 1`)
+	require.Contains(t, out, `gateway_upstream_request_duration_seconds_count{method="GET",service="news",status="error"} 1`)
+}
+
+func TestHandler(t *testing.T) {
+	FeedFetchErrors.WithLabelValues("https://example.com/rss").Inc()
+	require.Contains(t, gather(t), `rss_fetch_errors_total{source="https://example.com/rss"} 1`)
+}
+
+func gather(t *testing.T) string {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
@@ -0,0 +1,256 @@
+// Package middleware - общие HTTP middleware сервисов: сквозной идентификатор
+// запроса, журнал запросов в формате JSON, перехват паник и учет кода ответа.
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"news/pkg/apierror"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Откуда берется идентификатор запроса: заголовок имеет приоритет над параметром
+const (
+	HeaderRequestID = "X-Request-ID"
+	QueryRequestID  = "request_id"
+)
+
+// maxRequestIDLen - более длинные идентификаторы заменяются новыми
+const maxRequestIDLen = 128
+
+// contextKey - тип ключей контекста пакета, не пересекается с ключами других пакетов
+type contextKey struct{ name string }
+
+var requestIDKey = &contextKey{"request_id"}
+
+// WithRequestID сохраняет идентификатор запроса в контексте
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFrom возвращает идентификатор запроса из контекста или пустую строку
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// NewRequestID создает случайный идентификатор запроса
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req-" + hex.EncodeToString(b)
+}
+
+// validRequestID допускает только короткие идентификаторы из безопасных символов,
+// чтобы значение от клиента нельзя было использовать для подделки журнала
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID берет идентификатор из заголовка X-Request-ID или параметра request_id,
+// а если его нет - создает новый. Идентификатор сохраняется в контексте и
+// возвращается клиенту в заголовке X-Request-ID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = r.URL.Query().Get(QueryRequestID)
+		}
+		if !validRequestID(id) {
+			id = NewRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// Logger записывает по строке журнала на каждый запрос
+func Logger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := NewStatusRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.Bytes()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("request_id", RequestIDFrom(r.Context())),
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+			}
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+		})
+	}
+}
+
+// Recover перехватывает панику обработчика, записывает ее в журнал со стеком и,
+// если ответ еще не начат, возвращает клиенту ошибку 500 в едином формате
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := NewStatusRecorder(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// Штатный способ прервать ответ, например в httputil.ReverseProxy
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+					slog.Any("panic", v),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", RequestIDFrom(r.Context())),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !rec.Written() {
+					apierror.Write(rec, r, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "internal server error"))
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// DefaultTimeout - ограничение времени обработки обычного запроса
+const DefaultTimeout = 5 * time.Second
+
+// Timeout ограничивает время обработки запроса значением limit(r): по истечении
+// срока контекст запроса отменяется, и если обработчик так ничего и не ответил,
+// клиент получает 504. Ноль означает запрос без ограничения (SSE, WebSocket) -
+// для него снимаются и сроки чтения и записи соединения, заданные сервером.
+func Timeout(limit func(r *http.Request) time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := limit(r)
+			if d <= 0 {
+				rc := http.NewResponseController(w)
+				rc.SetReadDeadline(time.Time{})
+				rc.SetWriteDeadline(time.Time{})
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			rec := NewStatusRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			if ctx.Err() == context.DeadlineExceeded && !rec.Written() {
+				apierror.Write(rec, r, apierror.Timeout())
+			}
+		})
+	}
+}
+
+// Stack объединяет RequestID, Logger и Recover в порядке, в котором их
+// подключают все сервисы
+func Stack(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RequestID(Logger(logger)(Recover(logger)(next)))
+	}
+}
+
+// NewLogger создает журнал в формате JSON с именем сервиса в каждой записи
+func NewLogger(service string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil)).With(slog.String("service", service))
+}
+
+// Transport передает идентификатор запроса из контекста в заголовке X-Request-ID.
+// Заголовок, пришедший от клиента через прокси, заменяется проверенным значением.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		if id := RequestIDFrom(r.Context()); id != "" && r.Header.Get(HeaderRequestID) != id {
+			r = r.Clone(r.Context())
+			r.Header.Set(HeaderRequestID, id)
+		}
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripper func(r *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// StatusRecorder запоминает код ответа и размер тела
+type StatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// NewStatusRecorder оборачивает w. Повторная обертка не создается.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	if rec, ok := w.(*StatusRecorder); ok {
+		return rec
+	}
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status - код ответа; 200, если обработчик его не задал
+func (rec *StatusRecorder) Status() int { return rec.status }
+
+// Bytes - число записанных байт тела
+func (rec *StatusRecorder) Bytes() int64 { return rec.bytes }
+
+// Written сообщает, начата ли отправка ответа
+func (rec *StatusRecorder) Written() bool { return rec.wroteHeader }
+
+func (rec *StatusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader && code >= 200 {
+		rec.status, rec.wroteHeader = code, true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *StatusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к Flush исходного writer
+func (rec *StatusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack нужен websocket.Upgrader, который проверяет http.Hijacker напрямую
+func (rec *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status, rec.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFrom(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   string
+	}{
+		{name: "заголовок", header: "abc-123", query: "other", want: "abc-123"},
+		{name: "параметр", query: "from.query_1", want: "from.query_1"},
+		{name: "недопустимый заголовок", header: "bad id\n", query: "q-1", want: "q-1"},
+		{name: "создается", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/news?request_id="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if tt.want == "" {
+				require.True(t, strings.HasPrefix(got, "req-"), got)
+			} else {
+				require.Equal(t, tt.want, got)
+			}
+			require.Equal(t, got, rec.Header().Get(HeaderRequestID))
+		})
+	}
+}
+
+func TestStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := Stack(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "hello")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/comments", nil)
+	r.Header.Set(HeaderRequestID, "req-log")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "request", entry["msg"])
+	require.Equal(t, "POST", entry["method"])
+	require.Equal(t, "/comments", entry["path"])
+	require.Equal(t, float64(http.StatusCreated), entry["status"])
+	require.Equal(t, float64(5), entry["bytes"])
+	require.Equal(t, "req-log", entry["request_id"])
+
+	// Паника превращается в 500 в формате apierror и попадает в журнал
+	buf.Reset()
+	r = httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Header.Set(HeaderRequestID, "req-panic")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	var body struct {
+		Error struct {
+			Code      string `json:"code"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "internal", body.Error.Code)
+	require.Equal(t, "req-panic", body.Error.RequestID)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"panic"`)
+	require.Contains(t, lines[0], `"panic":"boom"`)
+	require.Contains(t, lines[1], `"status":500`)
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(HeaderRequestID)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	req, err := http.NewRequestWithContext(WithRequestID(t.Context(), "req-up"), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set(HeaderRequestID, "from-client")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, "req-up", got)
+	require.Equal(t, "from-client", req.Header.Get(HeaderRequestID))
+}
+
+func TestTimeout(t *testing.T) {
+	limit := func(r *http.Request) time.Duration {
+		if r.URL.Path == "/stream" {
+			return 0
+		}
+		return 20 * time.Millisecond
+	}
+	h := Timeout(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline := r.Context().Deadline()
+		switch r.URL.Path {
+		case "/slow":
+			<-r.Context().Done()
+		case "/stream":
+			require.False(t, hasDeadline)
+			io.WriteString(w, "stream")
+		default:
+			require.True(t, hasDeadline)
+			io.WriteString(w, "ok")
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"timeout"`)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "ok", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	require.Equal(t, "stream", rec.Body.String())
+}
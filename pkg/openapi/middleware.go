@@ -0,0 +1,209 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"news/pkg/apierror"
+)
+
+// EnvValidateResponses - переменная окружения, включающая проверку ответов (тестовый режим)
+const EnvValidateResponses = "OPENAPI_VALIDATE_RESPONSES"
+
+// maxBody - максимальный размер проверяемого тела запроса
+const maxBody = 1 << 20
+
+// Options настройки проверки
+type Options struct {
+	// ValidateResponses проверяет ответы обработчиков. Ответ буферизуется, а при
+	// несоответствии спецификации клиент получает 500. Предназначено для тестов.
+	ValidateResponses bool
+}
+
+// DefaultOptions включает проверку ответов, если задана переменная EnvValidateResponses
+func DefaultOptions() Options {
+	v := os.Getenv(EnvValidateResponses)
+	return Options{ValidateResponses: v != "" && v != "0" && v != "false"}
+}
+
+// Middleware проверяет запросы по спецификации. Пути, которых нет в спецификации
+// (статика), и запросы OPTIONS пропускаются без проверки.
+func (s *Spec) Middleware(opts Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			op, params, ok := s.Find(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if op == nil {
+				apierror.Write(w, r, apierror.MethodNotAllowed())
+				return
+			}
+
+			if errs := s.ValidateRequest(op, r, params); len(errs) > 0 {
+				apierror.Write(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidation,
+					"request does not match the API specification").WithDetails(errs))
+				return
+			}
+
+			if !opts.ValidateResponses || op.Streaming {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &recorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if errs := s.ValidateResponse(op, rec.status, rec.header, rec.body.Bytes()); len(errs) > 0 {
+				log.Printf("OpenAPI response mismatch | %s %s | %v", r.Method, r.URL.Path, errs)
+				apierror.Write(w, r, apierror.New(http.StatusInternalServerError, apierror.CodeInternal,
+					"response does not match the API specification").WithDetails(errs))
+				return
+			}
+			rec.flush(w)
+		})
+	}
+}
+
+// ValidateRequest проверяет параметры и тело запроса. Тело после проверки
+// снова доступно обработчику.
+func (s *Spec) ValidateRequest(op *Operation, r *http.Request, pathParams map[string]string) []string {
+	var errs []string
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "query":
+			raw, present = query.Get(p.Name), query.Has(p.Name)
+		case "path":
+			raw, present = pathParams[p.Name], true
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+		name := p.In + "." + p.Name
+		if !present || (raw == "" && p.In != "path") {
+			if p.Required {
+				errs = append(errs, name+": is required")
+			}
+			continue
+		}
+		v, err := s.coerce(p.Schema, raw)
+		if err != nil {
+			errs = append(errs, name+": "+err.Error())
+			continue
+		}
+		errs = append(errs, s.Validate(p.Schema, v, name)...)
+	}
+
+	if op.RequestBody == nil {
+		return errs
+	}
+	mt, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return errs
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return append(errs, "body: "+err.Error())
+	}
+	if len(body) > maxBody {
+		return append(errs, "body: too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, "body: is required")
+		}
+		return errs
+	}
+	value, err := decode(body)
+	if err != nil {
+		return append(errs, "body: invalid json")
+	}
+	return append(errs, s.Validate(mt.Schema, value, "body")...)
+}
+
+// ValidateResponse проверяет код и тело ответа
+func (s *Spec) ValidateResponse(op *Operation, status int, header http.Header, body []byte) []string {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses[strconv.Itoa(status/100)+"XX"]
+	}
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []string{"status " + strconv.Itoa(status) + " is not documented"}
+	}
+	if len(resp.Content) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	ct := header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	mt, ok := resp.Content[ct]
+	if !ok {
+		return []string{"content type " + strconv.Quote(ct) + " is not documented"}
+	}
+	if mt.Schema == nil || ct != "application/json" {
+		return nil
+	}
+	value, err := decode(body)
+	if err != nil {
+		return []string{"response: invalid json"}
+	}
+	return s.Validate(mt.Schema, value, "response")
+}
+
+func decode(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// recorder буферизует ответ до проверки
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header { return rec.header }
+
+func (rec *recorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = code, true
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) flush(w http.ResponseWriter) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
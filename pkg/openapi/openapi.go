@@ -0,0 +1,291 @@
+// Package openapi хранит спецификации OpenAPI 3 сервисов и проверяет
+// по ним запросы и (в тестовом режиме) ответы. Поддерживается подмножество
+// стандарта, которое используется в наших спецификациях.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed specs/*.json
+var specs embed.FS
+
+// Имена спецификаций сервисов
+const (
+	Gateway  = "gateway"
+	News     = "news"
+	Comments = "comments"
+	Censor   = "censor"
+)
+
+// Document - документ OpenAPI
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       json.RawMessage     `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// PathItem - операции пути по HTTP методам
+type PathItem struct {
+	Operations map[string]*Operation
+}
+
+func (p *PathItem) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	p.Operations = make(map[string]*Operation)
+	for _, m := range []string{"get", "put", "post", "delete", "patch", "head", "options"} {
+		if r, ok := raw[m]; ok {
+			var op Operation
+			if err := json.Unmarshal(r, &op); err != nil {
+				return fmt.Errorf("%s: %w", m, err)
+			}
+			p.Operations[strings.ToUpper(m)] = &op
+		}
+	}
+	return nil
+}
+
+// Operation - описание одной операции
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+	Streaming   bool                `json:"x-streaming"` // SSE или WebSocket: ответ не буферизуется и не проверяется
+	Timeout     float64             `json:"x-timeout"`   // ограничение времени обработки, секунды
+	Role        string              `json:"x-role"`      // минимальная роль клиента; пусто - доступно всем
+}
+
+// Parameter - параметр пути или строки запроса
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Spec - загруженная спецификация с таблицей маршрутов
+type Spec struct {
+	Doc    Document
+	raw    []byte
+	routes []route
+}
+
+// route - шаблон пути, разбитый на сегменты
+type route struct {
+	template string
+	segments []string
+	params   int
+	item     PathItem
+}
+
+// Load загружает встроенную спецификацию сервиса
+func Load(name string) (*Spec, error) {
+	raw, err := specs.ReadFile("specs/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("openapi: unknown spec %q", name)
+	}
+	return Parse(raw)
+}
+
+// MustLoad как Load, но паникует при ошибке. Спецификации встроены в бинарный файл,
+// поэтому ошибка означает ошибку сборки.
+func MustLoad(name string) *Spec {
+	s, err := Load(name)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Parse разбирает документ и проверяет ссылки на схемы
+func Parse(raw []byte) (*Spec, error) {
+	s := &Spec{raw: raw}
+	if err := json.Unmarshal(raw, &s.Doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	if !strings.HasPrefix(s.Doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q", s.Doc.OpenAPI)
+	}
+	for tmpl, item := range s.Doc.Paths {
+		r := route{template: tmpl, segments: strings.Split(strings.Trim(tmpl, "/"), "/"), item: item}
+		for _, seg := range r.segments {
+			if isParam(seg) {
+				r.params++
+			}
+		}
+		s.routes = append(s.routes, r)
+		for method, op := range item.Operations {
+			if err := s.checkRefs(op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", method, tmpl, err)
+			}
+		}
+	}
+	// Пути без параметров имеют приоритет: /comments/stats раньше /comments/{id}
+	sort.Slice(s.routes, func(i, j int) bool {
+		if s.routes[i].params != s.routes[j].params {
+			return s.routes[i].params < s.routes[j].params
+		}
+		return s.routes[i].template < s.routes[j].template
+	})
+	return s, nil
+}
+
+// Find находит операцию запроса. ok = false, если путь не описан;
+// op = nil, если путь описан, но метод не поддерживается.
+func (s *Spec) Find(method, path string) (op *Operation, params map[string]string, ok bool) {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, r := range s.routes {
+		params, matched := r.match(segments)
+		if !matched {
+			continue
+		}
+		return r.item.Operations[method], params, true
+	}
+	return nil, nil, false
+}
+
+// Route возвращает шаблон пути спецификации, например /news/{id}, или пустую
+// строку, если путь не описан
+func (s *Spec) Route(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, r := range s.routes {
+		if _, matched := r.match(segments); matched {
+			return r.template
+		}
+	}
+	return ""
+}
+
+// Timeout возвращает функцию, которая выбирает ограничение времени обработки
+// запроса: x-timeout операции или def. Для потоковых операций ограничения нет.
+func (s *Spec) Timeout(def time.Duration) func(r *http.Request) time.Duration {
+	return func(r *http.Request) time.Duration {
+		op, _, _ := s.Find(r.Method, r.URL.Path)
+		switch {
+		case op == nil:
+			return def
+		case op.Streaming:
+			return 0
+		case op.Timeout > 0:
+			return time.Duration(op.Timeout * float64(time.Second))
+		}
+		return def
+	}
+}
+
+// Role возвращает минимальную роль клиента для запроса (x-role операции).
+// Для неизвестных маршрутов и операций без x-role возвращается пустая строка.
+func (s *Spec) Role(r *http.Request) string {
+	if op, _, _ := s.Find(r.Method, r.URL.Path); op != nil {
+		return op.Role
+	}
+	return ""
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range r.segments {
+		if isParam(seg) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+// Handler отдает документ спецификации
+func (s *Spec) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.raw)
+	})
+}
+
+// checkRefs проверяет, что все $ref операции указывают на существующие схемы
+func (s *Spec) checkRefs(op *Operation) error {
+	var schemas []*Schema
+	for _, p := range op.Parameters {
+		schemas = append(schemas, p.Schema)
+	}
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content {
+			schemas = append(schemas, mt.Schema)
+		}
+	}
+	for _, resp := range op.Responses {
+		for _, mt := range resp.Content {
+			schemas = append(schemas, mt.Schema)
+		}
+	}
+	seen := make(map[*Schema]bool)
+	for _, sch := range schemas {
+		if err := s.walkRefs(sch, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Spec) walkRefs(sch *Schema, seen map[*Schema]bool) error {
+	if sch == nil || seen[sch] {
+		return nil
+	}
+	seen[sch] = true
+	if sch.Ref != "" {
+		target, err := s.resolve(sch)
+		if err != nil {
+			return err
+		}
+		return s.walkRefs(target, seen)
+	}
+	for _, p := range sch.Properties {
+		if err := s.walkRefs(p, seen); err != nil {
+			return err
+		}
+	}
+	return s.walkRefs(sch.Items, seen)
+}
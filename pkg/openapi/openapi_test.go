@@ -0,0 +1,148 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAll(t *testing.T) {
+	for _, name := range []string{Gateway, News, Comments, Censor} {
+		s, err := Load(name)
+		require.NoError(t, err, name)
+		op, _, ok := s.Find(http.MethodGet, "/openapi.json")
+		require.True(t, ok, name)
+		require.NotNil(t, op, name)
+	}
+	_, err := Load("unknown")
+	require.Error(t, err)
+}
+
+func TestFind(t *testing.T) {
+	s := MustLoad(Comments)
+
+	op, params, ok := s.Find(http.MethodGet, "/comments/stats")
+	require.True(t, ok)
+	require.Equal(t, "commentStats", op.OperationID)
+	require.Empty(t, params)
+
+	op, params, ok = s.Find(http.MethodPut, "/comments/12")
+	require.True(t, ok)
+	require.Equal(t, "editComment", op.OperationID)
+	require.Equal(t, map[string]string{"id": "12"}, params)
+
+	// Путь описан, метод нет
+	op, _, ok = s.Find(http.MethodPatch, "/comments/12")
+	require.True(t, ok)
+	require.Nil(t, op)
+
+	_, _, ok = s.Find(http.MethodGet, "/index.html")
+	require.False(t, ok)
+}
+
+func TestTimeout(t *testing.T) {
+	timeout := MustLoad(Gateway).Timeout(5 * time.Second)
+	get := func(path string) time.Duration {
+		return timeout(httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	require.Equal(t, 5*time.Second, get("/news"))
+	require.Equal(t, 10*time.Second, get("/news/popular"))
+	require.Equal(t, 5*time.Second, get("/index.html"))
+	require.Zero(t, get("/news/stream"))
+	require.Zero(t, get("/comments/ws"))
+}
+
+func TestRole(t *testing.T) {
+	s := MustLoad(Gateway)
+	role := func(method, path string) string {
+		return s.Role(httptest.NewRequest(method, path, nil))
+	}
+	require.Equal(t, "reader", role(http.MethodGet, "/news"))
+	require.Equal(t, "commenter", role(http.MethodPost, "/comment/add"))
+	require.Equal(t, "moderator", role(http.MethodDelete, "/comments/12"))
+	require.Equal(t, "admin", role(http.MethodPost, "/admin/keys"))
+	require.Empty(t, role(http.MethodGet, "/healthz"))
+	require.Empty(t, role(http.MethodGet, "/index.html"))
+}
+
+func TestValidateRequest(t *testing.T) {
+	s := MustLoad(Comments)
+
+	check := func(method, target, body string) []string {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		op, params, _ := s.Find(method, r.URL.Path)
+		return s.ValidateRequest(op, r, params)
+	}
+
+	require.Empty(t, check(http.MethodGet, "/comments?news_id=3", ""))
+	require.Equal(t, []string{"query.news_id: is required"}, check(http.MethodGet, "/comments", ""))
+	require.Equal(t, []string{"query.news_id: must be an integer"}, check(http.MethodGet, "/comments?news_id=abc", ""))
+	require.Equal(t, []string{"query.limit: must be <= 100"}, check(http.MethodGet, "/comments/stats?limit=1000", ""))
+	require.Equal(t, []string{"path.id: must be >= 1"}, check(http.MethodDelete, "/comments/0", ""))
+
+	require.Empty(t, check(http.MethodPost, "/comments", `{"news_id": 1, "author": "alice", "text": "привет"}`))
+	require.Equal(t, []string{"body.news_id: must be an integer", "body.text: must be at least 1 characters"},
+		check(http.MethodPost, "/comments", `{"news_id": 1.5, "text": ""}`))
+	require.Equal(t, []string{"body.news_id: is required"}, check(http.MethodPost, "/comments", `{"text": "привет"}`))
+	require.Equal(t, []string{"body: is required"}, check(http.MethodPost, "/comments", ""))
+	require.Equal(t, []string{"body: invalid json"}, check(http.MethodPost, "/comments", "{"))
+}
+
+func TestValidateResponse(t *testing.T) {
+	s := MustLoad(Comments)
+	op, _, _ := s.Find(http.MethodGet, "/comments")
+	h := http.Header{"Content-Type": {"application/json"}}
+
+	ok := `[{"id": 1, "news_id": 2, "parent_id": null, "author": "a", "author_id": null, "text": "t", "created_at": "2024-05-01T12:00:00Z"}]`
+	require.Empty(t, s.ValidateResponse(op, http.StatusOK, h, []byte(ok)))
+
+	require.Equal(t, []string{"response[0].created_at: is required", "response[0].id: must be an integer"},
+		s.ValidateResponse(op, http.StatusOK, h, []byte(`[{"id": "1", "news_id": 2, "parent_id": null, "author": "a", "author_id": 7, "text": "t"}]`)))
+
+	// Ошибки проверяются по схеме default
+	require.Empty(t, s.ValidateResponse(op, http.StatusBadRequest, h, []byte(`{"error": {"code": "bad_request", "message": "x"}}`)))
+	require.NotEmpty(t, s.ValidateResponse(op, http.StatusBadRequest, h, []byte(`{"message": "x"}`)))
+}
+
+func TestMiddleware(t *testing.T) {
+	s := MustLoad(Comments)
+	var body string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	srv := httptest.NewServer(s.Middleware(Options{ValidateResponses: true})(handler))
+	defer srv.Close()
+
+	get := func(path string) (int, map[string]any) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var m map[string]any
+		json.NewDecoder(resp.Body).Decode(&m)
+		return resp.StatusCode, m
+	}
+
+	body = `[]`
+	status, _ := get("/comments?news_id=1")
+	require.Equal(t, http.StatusOK, status)
+
+	status, m := get("/comments")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "validation_failed", m["error"].(map[string]any)["code"])
+
+	// Ответ не соответствует спецификации
+	body = `{"oops": true}`
+	status, m = get("/comments?news_id=1")
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, []any{"response: must be an array"}, m["error"].(map[string]any)["details"])
+
+	// Пути вне спецификации не проверяются
+	status, _ = get("/static/app.js")
+	require.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema - подмножество JSON Schema из OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Nullable             bool               `json:"nullable"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+func (s *Spec) resolve(sch *Schema) (*Schema, error) {
+	for depth := 0; sch != nil && sch.Ref != ""; depth++ {
+		if depth > 16 || !strings.HasPrefix(sch.Ref, refPrefix) {
+			return nil, fmt.Errorf("unsupported $ref %q", sch.Ref)
+		}
+		target, ok := s.Doc.Components.Schemas[strings.TrimPrefix(sch.Ref, refPrefix)]
+		if !ok {
+			return nil, fmt.Errorf("unknown $ref %q", sch.Ref)
+		}
+		sch = target
+	}
+	return sch, nil
+}
+
+// Validate проверяет значение, разобранное json.Decoder с UseNumber,
+// и возвращает список нарушений с путями к полям
+func (s *Spec) Validate(sch *Schema, value any, path string) []string {
+	var errs []string
+	s.validate(sch, value, path, &errs)
+	return errs
+}
+
+func (s *Spec) validate(sch *Schema, value any, path string, errs *[]string) {
+	sch, err := s.resolve(sch)
+	if err != nil {
+		*errs = append(*errs, path+": "+err.Error())
+		return
+	}
+	if sch == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		if !sch.Nullable && sch.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch sch.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range sch.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, join(path, name)+": is required")
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := sch.Properties[k]
+			if !ok {
+				if sch.AdditionalProperties != nil && !*sch.AdditionalProperties {
+					*errs = append(*errs, join(path, k)+": unknown field")
+				}
+				continue
+			}
+			s.validate(prop, obj[k], join(path, k), errs)
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, v := range arr {
+			s.validate(sch.Items, v, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := len([]rune(str))
+		if sch.MinLength != nil && n < *sch.MinLength {
+			fail("must be at least %d characters", *sch.MinLength)
+		}
+		if sch.MaxLength != nil && n > *sch.MaxLength {
+			fail("must be at most %d characters", *sch.MaxLength)
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be %s", map[string]string{"integer": "an integer", "number": "a number"}[sch.Type])
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if sch.Type == "integer" {
+			if _, err := strconv.ParseInt(num.String(), 10, 64); err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		if sch.Minimum != nil && f < *sch.Minimum {
+			fail("must be >= %v", *sch.Minimum)
+		}
+		if sch.Maximum != nil && f > *sch.Maximum {
+			fail("must be <= %v", *sch.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if len(sch.Enum) > 0 && !inEnum(sch.Enum, value) {
+		fail("must be one of %v", sch.Enum)
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// coerce преобразует строковое значение параметра к типу схемы
+func (s *Spec) coerce(sch *Schema, raw string) (any, error) {
+	sch, err := s.resolve(sch)
+	if err != nil || sch == nil {
+		return raw, err
+	}
+	switch sch.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory создает этап по параметрам из конфигурации
+type Factory func(params json.RawMessage) (Stage, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register регистрирует тип этапа. Повторная регистрация заменяет фабрику,
+// что позволяет приложению подключать этапы, которым нужны внешние зависимости.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Types возвращает зарегистрированные типы этапов
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StageConfig - описание этапа в конфигурации
+type StageConfig struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Config - конвейеры по умолчанию и для отдельных лент.
+// Этапы ленты полностью заменяют этапы по умолчанию.
+type Config struct {
+	Default []StageConfig            `json:"default"`
+	Sources map[string][]StageConfig `json:"sources"`
+}
+
+// DefaultStages используются, если конфигурация не задает этапы по умолчанию
+var DefaultStages = []StageConfig{
+	{Type: "normalize_date"},
+	{Type: "sanitize"},
+	{Type: "dedup"},
+}
+
+// Set - набор конвейеров по лентам
+type Set struct {
+	def     *Pipeline
+	sources map[string]*Pipeline
+}
+
+// Build создает конвейеры по конфигурации
+func Build(cfg Config) (*Set, error) {
+	stages := cfg.Default
+	if len(stages) == 0 {
+		stages = DefaultStages
+	}
+	def, err := buildPipeline(stages)
+	if err != nil {
+		return nil, fmt.Errorf("default pipeline: %w", err)
+	}
+
+	set := &Set{def: def, sources: make(map[string]*Pipeline)}
+	for source, stages := range cfg.Sources {
+		p, err := buildPipeline(stages)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline for %s: %w", source, err)
+		}
+		set.sources[source] = p
+	}
+	return set, nil
+}
+
+// For возвращает конвейер для ленты
+func (s *Set) For(source string) *Pipeline {
+	if p, ok := s.sources[source]; ok {
+		return p
+	}
+	return s.def
+}
+
+func buildPipeline(configs []StageConfig) (*Pipeline, error) {
+	var stages []Stage
+	for i, sc := range configs {
+		registryMu.RLock()
+		factory, ok := registry[sc.Type]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("stage %d: unknown type %q", i, sc.Type)
+		}
+		stage, err := factory(sc.Params)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i, sc.Type, err)
+		}
+		stages = append(stages, stage)
+	}
+	return New(stages...), nil
+}
+
+// DecodeParams разбирает параметры этапа; пустые параметры допустимы, неизвестные поля - нет.
+// Нужна и фабрикам этапов из других пакетов.
+func DecodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register("keywords", newKeywords)
+}
+
+// Rule - условие отбора элемента. Заданные поля объединяются по И.
+// В конфигурации правило можно задать строкой - это ключевое слово.
+type Rule struct {
+	Keyword  string `json:"keyword,omitempty"`  // подстрока без учета регистра
+	Regex    string `json:"regex,omitempty"`    // регулярное выражение
+	Field    string `json:"field,omitempty"`    // где искать keyword/regex: title, content или any (по умолчанию)
+	Category string `json:"category,omitempty"` // категория элемента, без учета регистра
+	Author   string `json:"author,omitempty"`   // подстрока в имени автора, без учета регистра
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	var keyword string
+	if json.Unmarshal(b, &keyword) == nil {
+		*r = Rule{Keyword: keyword}
+		return nil
+	}
+	// Отдельный тип без UnmarshalJSON, чтобы не уйти в рекурсию
+	type rule Rule
+	return DecodeParams(b, (*rule)(r))
+}
+
+// compile проверяет правило и готовит его к использованию
+func (r *Rule) compile() error {
+	if r.Keyword == "" && r.Regex == "" && r.Category == "" && r.Author == "" {
+		return errors.New("empty rule")
+	}
+	switch r.Field {
+	case "", "any", "title", "content":
+	default:
+		return fmt.Errorf("unknown field %q", r.Field)
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return err
+		}
+		r.re = re
+	}
+	r.Keyword = strings.ToLower(r.Keyword)
+	r.Author = strings.ToLower(r.Author)
+	return nil
+}
+
+// match проверяет элемент по всем условиям правила
+func (r *Rule) match(item *Item) bool {
+	var text string
+	switch r.Field {
+	case "title":
+		text = item.Post.Title
+	case "content":
+		text = item.Post.Content
+	default:
+		text = item.Post.Title + "\n" + item.Post.Content
+	}
+
+	if r.Keyword != "" && !strings.Contains(strings.ToLower(text), r.Keyword) {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(text) {
+		return false
+	}
+	if r.Category != "" && !hasCategory(item.Raw.Categories, r.Category) {
+		return false
+	}
+	if r.Author != "" && !strings.Contains(strings.ToLower(item.Raw.AuthorName()), r.Author) {
+		return false
+	}
+	return true
+}
+
+// String описывает правило для причины отказа
+func (r *Rule) String() string {
+	var parts []string
+	if r.Keyword != "" {
+		parts = append(parts, fmt.Sprintf("keyword %q", r.Keyword))
+	}
+	if r.Regex != "" {
+		parts = append(parts, fmt.Sprintf("regex %q", r.Regex))
+	}
+	if r.Field != "" && r.Field != "any" {
+		parts = append(parts, "in "+r.Field)
+	}
+	if r.Category != "" {
+		parts = append(parts, fmt.Sprintf("category %q", r.Category))
+	}
+	if r.Author != "" {
+		parts = append(parts, fmt.Sprintf("author %q", r.Author))
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasCategory(categories []string, want string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(strings.TrimSpace(c), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// keywords отбирает элементы по правилам: элемент должен подойти хотя бы под одно
+// правило Include (если они заданы) и ни под одно правило Exclude
+type keywords struct {
+	Include []Rule `json:"include"`
+	Exclude []Rule `json:"exclude"`
+}
+
+func newKeywords(params json.RawMessage) (Stage, error) {
+	s := &keywords{}
+	if err := DecodeParams(params, s); err != nil {
+		return nil, err
+	}
+	for i := range s.Include {
+		if err := s.Include[i].compile(); err != nil {
+			return nil, fmt.Errorf("include rule %d: %w", i, err)
+		}
+	}
+	for i := range s.Exclude {
+		if err := s.Exclude[i].compile(); err != nil {
+			return nil, fmt.Errorf("exclude rule %d: %w", i, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *keywords) Name() string { return "keywords" }
+
+func (s *keywords) Process(item *Item) error {
+	for i := range s.Exclude {
+		if s.Exclude[i].match(item) {
+			return Reject("matched exclude rule #%d (%s)", i+1, &s.Exclude[i])
+		}
+	}
+	if len(s.Include) == 0 {
+		return nil
+	}
+	for i := range s.Include {
+		if s.Include[i].match(item) {
+			return nil
+		}
+	}
+	return Reject("matched no include rule")
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+
+	"news/pkg/postgres"
+	"news/pkg/rss"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordsStage(t *testing.T) {
+	stage, err := newKeywords(json.RawMessage(`{
+		"include": [
+			"процессор",
+			{"category": "Hardware", "author": "иванов"}
+		],
+		"exclude": [
+			{"regex": "(?i)промокод", "field": "title"},
+			{"category": "Реклама"}
+		]
+	}`))
+	require.NoError(t, err)
+	p := New(stage)
+
+	res := p.Run("src", []rss.Item{
+		{Title: "Новый процессор"},
+		{Title: "Видеокарта", Categories: []string{"hardware"}, Creator: "Петр Иванов"},
+		{Title: "Видеокарта", Categories: []string{"hardware"}, Author: "Сидоров"},
+		{Title: "Процессор со скидкой по ПРОМОКОДУ"},
+		{Title: "Процессор", Categories: []string{" реклама "}},
+	})
+
+	require.Len(t, res.Accepted, 2)
+	require.Len(t, res.Rejected, 3)
+	require.Equal(t, "matched no include rule", res.Rejected[0].Reason)
+	require.Equal(t, `matched exclude rule #1 (regex "(?i)промокод" in title)`, res.Rejected[1].Reason)
+	require.Equal(t, `matched exclude rule #2 (category "Реклама")`, res.Rejected[2].Reason)
+}
+
+func TestKeywordsValidation(t *testing.T) {
+	_, err := newKeywords(json.RawMessage(`{"exclude": [{}]}`))
+	require.Error(t, err)
+
+	_, err = newKeywords(json.RawMessage(`{"exclude": [{"regex": "("}]}`))
+	require.Error(t, err)
+
+	_, err = newKeywords(json.RawMessage(`{"include": [{"keyword": "x", "field": "body"}]}`))
+	require.Error(t, err)
+
+	_, err = newKeywords(json.RawMessage(`{"include": [{"keywrod": "x"}]}`))
+	require.Error(t, err)
+}
+
+func TestRunnerDryRun(t *testing.T) {
+	set, err := Build(Config{})
+	require.NoError(t, err)
+	runner := NewRunner(set)
+
+	batch := rss.Batch{Source: "src", Items: []rss.Item{
+		{Title: "Первая", Link: "https://example.com/1"},
+		{Title: "Спонсорский пост", Link: "https://example.com/2"},
+	}}
+	require.Len(t, runner.Run(batch).Accepted, 2)
+	require.Equal(t, []string{"src"}, runner.Sources())
+
+	// Пока посты не сохранены, dedup их не помнит
+	report, err := runner.DryRun("src", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Accepted)
+
+	// После сохранения dry-run показывает их как дубликаты
+	runner.Commit("src", []postgres.Post{{ID: 1, Title: "Первая", Link: "https://example.com/1"}})
+	report, err = runner.DryRun("src", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Rejected)
+	require.Equal(t, "dedup", report.Items[0].Stage)
+	require.True(t, report.Items[1].Accepted)
+
+	// Предлагаемые правила
+	report, err = runner.DryRun("src", []StageConfig{
+		{Type: "keywords", Params: json.RawMessage(`{"exclude": [{"keyword": "спонсор"}]}`)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"keywords"}, report.Stages)
+	require.Equal(t, 1, report.Rejected)
+	require.False(t, report.Items[1].Accepted)
+	require.Equal(t, "keywords", report.Items[1].Stage)
+
+	_, err = runner.DryRun("unknown", nil)
+	require.ErrorIs(t, err, ErrUnknownSource)
+}
@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"encoding/json"
+
+	"news/pkg/langdetect"
+)
+
+func init() {
+	Register("detect_lang", newDetectLang)
+}
+
+// detectLang определяет язык поста по заголовку и тексту
+type detectLang struct {
+	Default string `json:"default"` // язык, если определить не удалось
+}
+
+func newDetectLang(params json.RawMessage) (Stage, error) {
+	s := &detectLang{}
+	return s, DecodeParams(params, s)
+}
+
+func (s *detectLang) Name() string { return "detect_lang" }
+
+func (s *detectLang) Process(item *Item) error {
+	lang := langdetect.Detect(item.Post.Title + ". " + PlainText(item.Post.Content))
+	if lang == langdetect.Unknown {
+		lang = s.Default
+	}
+	item.Post.Lang = lang
+	return nil
+}
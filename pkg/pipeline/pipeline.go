@@ -0,0 +1,153 @@
+// Package pipeline преобразует элементы RSS лент в посты для БД
+// через настраиваемую цепочку этапов (фильтрация, нормализация, обогащение).
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+
+	"news/pkg/postgres"
+	"news/pkg/rss"
+)
+
+// Item - элемент ленты в процессе обработки
+type Item struct {
+	Source string        // URL ленты
+	Raw    rss.Item      // исходный элемент ленты
+	Post   postgres.Post // пост, который будет сохранен в БД
+
+	batch map[string]bool // ключи dedup, уже принятые в этой пачке
+}
+
+// Stage - один этап обработки. Этап может изменить элемент
+// или отклонить его, вернув ошибку, созданную Reject.
+type Stage interface {
+	Name() string
+	Process(item *Item) error
+}
+
+// Committer - этап, которому нужно знать, какие посты сохранены в БД.
+// Commit вызывается только после успешной записи, поэтому состояние этапа
+// не расходится с БД, если запись не удалась.
+type Committer interface {
+	Commit(posts []postgres.Post)
+}
+
+// RejectError - отказ этапа пропускать элемент дальше
+type RejectError struct {
+	Reason    string
+	Duplicate bool // элемент уже сохранен раньше; такие отказы не стоит журналировать
+}
+
+func (e *RejectError) Error() string {
+	return "rejected: " + e.Reason
+}
+
+// Reject создает ошибку отказа с причиной
+func Reject(format string, args ...any) error {
+	return &RejectError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Duplicate создает отказ для элемента, который уже был сохранен
+func Duplicate(format string, args ...any) error {
+	return &RejectError{Reason: fmt.Sprintf(format, args...), Duplicate: true}
+}
+
+// Rejection - отклоненный элемент и причина
+type Rejection struct {
+	Stage     string `json:"stage"`
+	Reason    string `json:"reason"`
+	Duplicate bool   `json:"-"`
+	Item      Item   `json:"-"`
+}
+
+// Result - итог обработки пачки элементов
+type Result struct {
+	Accepted []Item
+	Rejected []Rejection
+}
+
+// Posts возвращает посты из принятых элементов
+func (r Result) Posts() []postgres.Post {
+	posts := make([]postgres.Post, 0, len(r.Accepted))
+	for _, item := range r.Accepted {
+		posts = append(posts, item.Post)
+	}
+	return posts
+}
+
+// Pipeline - упорядоченная цепочка этапов
+type Pipeline struct {
+	stages []Stage
+}
+
+// New создает конвейер из этапов
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Stages возвращает имена этапов по порядку
+func (p *Pipeline) Stages() []string {
+	names := make([]string, 0, len(p.stages))
+	for _, s := range p.stages {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+// Run пропускает элементы ленты source через все этапы. Этапы-Committer
+// узнают о принятых элементах только из Commit после записи в БД.
+func (p *Pipeline) Run(source string, items []rss.Item) Result {
+	var res Result
+	batch := make(map[string]bool)
+	for _, raw := range items {
+		item := newItem(source, raw)
+		item.batch = batch
+		if rej, ok := p.process(&item); !ok {
+			res.Rejected = append(res.Rejected, rej)
+			continue
+		}
+		res.Accepted = append(res.Accepted, item)
+	}
+	return res
+}
+
+// Commit сообщает этапам-Committer о постах, сохраненных в БД
+func (p *Pipeline) Commit(posts []postgres.Post) {
+	for _, stage := range p.stages {
+		if c, ok := stage.(Committer); ok {
+			c.Commit(posts)
+		}
+	}
+}
+
+// process прогоняет один элемент; при отказе возвращает false
+func (p *Pipeline) process(item *Item) (Rejection, bool) {
+	for _, stage := range p.stages {
+		err := stage.Process(item)
+		if err == nil {
+			continue
+		}
+		rej := Rejection{Stage: stage.Name(), Reason: err.Error(), Item: *item}
+		var rejectErr *RejectError
+		if errors.As(err, &rejectErr) {
+			rej.Reason, rej.Duplicate = rejectErr.Reason, rejectErr.Duplicate
+		}
+		return rej, false
+	}
+	return Rejection{}, true
+}
+
+// newItem заполняет пост исходными полями элемента
+func newItem(source string, raw rss.Item) Item {
+	return Item{
+		Source: source,
+		Raw:    raw,
+		Post: postgres.Post{
+			Title:   raw.Title,
+			Content: raw.Сontent,
+			Link:    raw.Link,
+			Source:  source,
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"news/pkg/rss"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineDefault(t *testing.T) {
+	set, err := Build(Config{})
+	require.NoError(t, err)
+	p := set.For("https://example.com/rss")
+	require.Equal(t, []string{"normalize_date", "sanitize", "dedup"}, p.Stages())
+
+	res := p.Run("https://example.com/rss", []rss.Item{
+		{
+			Title:   " <b>Новость</b>  дня ",
+			Link:    "https://example.com/a?utm_source=rss",
+			Сontent: `<p onclick="x()">Текст<script>alert(1)</script> <a href="javascript:x()">ссылка</a></p>`,
+			PubDate: "Mon, 02 Jan 2006 15:04:05 +0300",
+		},
+		{Title: "Дубль", Link: "https://example.com/a#comments"},
+		{Title: "", Link: "https://example.com/b"},
+	})
+
+	require.Len(t, res.Accepted, 1)
+	post := res.Accepted[0].Post
+	require.Equal(t, "Новость дня", post.Title)
+	require.Equal(t, "<p>Текст <a>ссылка</a></p>", post.Content)
+	require.Equal(t, "https://example.com/rss", post.Source)
+	require.Equal(t, time.Date(2006, 1, 2, 12, 4, 5, 0, time.UTC).Unix(), post.PubTime)
+
+	require.Len(t, res.Rejected, 2)
+	require.Equal(t, "dedup", res.Rejected[0].Stage)
+	require.Equal(t, "sanitize", res.Rejected[1].Stage)
+	require.Equal(t, "empty title", res.Rejected[1].Reason)
+}
+
+func TestPipelinePerSource(t *testing.T) {
+	set, err := Build(Config{
+		Sources: map[string][]StageConfig{
+			"ads": {
+				{Type: "keywords", Params: json.RawMessage(`{"exclude": ["Реклама"]}`)},
+				{Type: "normalize_date", Params: json.RawMessage(`{"reject_invalid": true}`)},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	res := set.For("ads").Run("ads", []rss.Item{
+		{Title: "Партнерский материал", Сontent: "реклама", PubDate: time.Now().Format(time.RFC1123Z)},
+		{Title: "Без даты", PubDate: "вчера"},
+		{Title: "Нормальная", PubDate: time.Now().Format(time.RFC1123Z)},
+	})
+	require.Len(t, res.Accepted, 1)
+	require.Equal(t, "Нормальная", res.Accepted[0].Post.Title)
+	require.Equal(t, `matched exclude rule #1 (keyword "реклама")`, res.Rejected[0].Reason)
+	require.Equal(t, "normalize_date", res.Rejected[1].Stage)
+
+	// Для остальных лент используется конвейер по умолчанию
+	require.Len(t, set.For("other").Stages(), len(DefaultStages))
+}
+
+func TestBuildErrors(t *testing.T) {
+	_, err := Build(Config{Default: []StageConfig{{Type: "unknown"}}})
+	require.Error(t, err)
+
+	_, err = Build(Config{Default: []StageConfig{{Type: "dedup", Params: json.RawMessage(`{"kyes": ["link"]}`)}}})
+	require.Error(t, err)
+}
+
+func TestDedupCommit(t *testing.T) {
+	set, err := Build(Config{Default: []StageConfig{{Type: "dedup"}}})
+	require.NoError(t, err)
+	p := set.For("src")
+	items := []rss.Item{{Title: "Первая", Link: "https://example.com/1"}, {Title: "Вторая", Link: "https://example.com/2"}}
+
+	// Пока пачка не сохранена, повторная загрузка ее не отклоняет
+	res := p.Run("src", items)
+	require.Len(t, res.Accepted, 2)
+	res = p.Run("src", items)
+	require.Len(t, res.Accepted, 2)
+
+	p.Commit(res.Posts()[:1])
+	res = p.Run("src", items)
+	require.Len(t, res.Accepted, 1)
+	require.Equal(t, "Вторая", res.Accepted[0].Post.Title)
+	require.True(t, res.Rejected[0].Duplicate)
+}
+
+func TestNormalizeLink(t *testing.T) {
+	require.Equal(t, "https://example.com/news/1?id=5",
+		normalizeLink("HTTP://Example.com/news/1/?utm_source=x&id=5#top"))
+}
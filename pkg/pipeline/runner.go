@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"news/pkg/postgres"
+	"news/pkg/rss"
+)
+
+// ErrUnknownSource возвращается, если для ленты еще нет загруженных элементов
+var ErrUnknownSource = errors.New("no fetched items for source")
+
+// Runner применяет конвейеры к пачкам из лент и запоминает последнюю пачку
+// каждой ленты, чтобы можно было проверить правила в режиме dry-run.
+type Runner struct {
+	mu     sync.RWMutex
+	set    *Set
+	latest map[string][]rss.Item
+}
+
+func NewRunner(set *Set) *Runner {
+	return &Runner{
+		set:    set,
+		latest: make(map[string][]rss.Item),
+	}
+}
+
+// Run обрабатывает пачку конвейером ее ленты
+func (r *Runner) Run(batch rss.Batch) Result {
+	r.mu.Lock()
+	r.latest[batch.Source] = batch.Items
+	set := r.set
+	r.mu.Unlock()
+
+	return set.For(batch.Source).Run(batch.Source, batch.Items)
+}
+
+// Commit сообщает конвейеру ленты о постах, сохраненных в БД
+func (r *Runner) Commit(source string, posts []postgres.Post) {
+	r.mu.RLock()
+	set := r.set
+	r.mu.RUnlock()
+	set.For(source).Commit(posts)
+}
+
+// Sources возвращает ленты, для которых есть загруженные элементы
+func (r *Runner) Sources() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sources := make([]string, 0, len(r.latest))
+	for s := range r.latest {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// DryRunItem - решение конвейера по одному элементу
+type DryRunItem struct {
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+	Accepted bool   `json:"accepted"`
+	Stage    string `json:"stage,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// DryRunReport - результат проверки последней пачки ленты
+type DryRunReport struct {
+	Source   string       `json:"source"`
+	Stages   []string     `json:"stages"`
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Items    []DryRunItem `json:"items"`
+}
+
+// DryRun прогоняет последнюю пачку ленты без сохранения состояния этапов.
+// Если stages не пусты, вместо настроенного конвейера проверяются они.
+func (r *Runner) DryRun(source string, stages []StageConfig) (DryRunReport, error) {
+	r.mu.RLock()
+	items, ok := r.latest[source]
+	set := r.set
+	r.mu.RUnlock()
+	if !ok {
+		return DryRunReport{}, ErrUnknownSource
+	}
+
+	p := set.For(source)
+	if len(stages) > 0 {
+		var err error
+		p, err = buildPipeline(stages)
+		if err != nil {
+			return DryRunReport{}, err
+		}
+	}
+
+	report := DryRunReport{Source: source, Stages: p.Stages(), Items: []DryRunItem{}}
+	batch := make(map[string]bool)
+	for _, raw := range items {
+		item := newItem(source, raw)
+		item.batch = batch
+		entry := DryRunItem{Title: raw.Title, Link: raw.Link, Accepted: true}
+		if rej, ok := p.process(&item); !ok {
+			entry.Accepted = false
+			entry.Stage = rej.Stage
+			entry.Reason = rej.Reason
+			report.Rejected++
+		} else {
+			report.Accepted++
+		}
+		report.Items = append(report.Items, entry)
+	}
+	return report, nil
+}
@@ -0,0 +1,342 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"news/pkg/postgres"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	Register("normalize_date", newNormalizeDate)
+	Register("sanitize", newSanitize)
+	Register("dedup", newDedup)
+	Register("tags", newStaticTags)
+}
+
+// ---- normalize_date ----
+
+// dateLayouts - форматы дат, встречающиеся в RSS и Atom лентах
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+}
+
+// normalizeDate разбирает pubDate и заполняет Post.PubTime
+type normalizeDate struct {
+	RejectInvalid bool `json:"reject_invalid"` // отклонять элементы без корректной даты вместо подстановки текущего времени
+	now           func() time.Time
+}
+
+func newNormalizeDate(params json.RawMessage) (Stage, error) {
+	s := &normalizeDate{now: time.Now}
+	return s, DecodeParams(params, s)
+}
+
+func (s *normalizeDate) Name() string { return "normalize_date" }
+
+func (s *normalizeDate) Process(item *Item) error {
+	now := s.now()
+	pubTime, ok := parseDate(item.Raw.PubDate)
+	if !ok {
+		if s.RejectInvalid {
+			return Reject("invalid pubDate %q", item.Raw.PubDate)
+		}
+		pubTime = now
+	}
+	// Даты из будущего обычно означают неверный часовой пояс ленты
+	if pubTime.After(now) {
+		pubTime = now
+	}
+	item.Post.PubTime = pubTime.Unix()
+	return nil
+}
+
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ---- sanitize ----
+
+// Теги, которые остаются в Content, и разрешенные у них атрибуты
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "b": nil, "i": nil, "strong": nil, "em": nil, "u": nil,
+	"ul": nil, "ol": nil, "li": nil, "blockquote": nil, "pre": nil, "code": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"figure": nil, "figcaption": nil,
+	"a":   {"href", "title"},
+	"img": {"src", "alt", "title"},
+}
+
+// Теги, содержимое которых удаляется целиком
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "noscript": true, "form": true, "template": true,
+}
+
+// sanitize очищает заголовок от разметки, а содержимое - от опасных тегов и атрибутов
+type sanitize struct {
+	StripHTML bool `json:"strip_html"` // оставлять в Content только текст
+}
+
+func newSanitize(params json.RawMessage) (Stage, error) {
+	s := &sanitize{}
+	return s, DecodeParams(params, s)
+}
+
+func (s *sanitize) Name() string { return "sanitize" }
+
+func (s *sanitize) Process(item *Item) error {
+	item.Post.Title = collapseSpaces(sanitizeHTML(item.Post.Title, true))
+	item.Post.Content = strings.TrimSpace(sanitizeHTML(item.Post.Content, s.StripHTML))
+	item.Post.Link = strings.TrimSpace(item.Post.Link)
+
+	if item.Post.Title == "" {
+		return Reject("empty title")
+	}
+	if !safeURL(item.Post.Link) || item.Post.Link == "" {
+		return Reject("invalid link %q", item.Post.Link)
+	}
+	return nil
+}
+
+// sanitizeHTML оставляет разрешенную разметку или, если textOnly, только текст
+func sanitizeHTML(src string, textOnly bool) string {
+	var out bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(src))
+	skip := 0 // глубина вложенности внутри удаляемых тегов
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.String()
+		}
+		tok := z.Token()
+
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				if textOnly {
+					out.WriteString(tok.Data)
+				} else {
+					out.WriteString(html.EscapeString(tok.Data))
+				}
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if textOnly {
+				if tok.Data == "br" || tok.Data == "p" || tok.Data == "li" {
+					out.WriteByte(' ')
+				}
+				continue
+			}
+			if attrs, ok := allowedTags[tok.Data]; ok {
+				tok.Attr = filterAttrs(tok.Attr, attrs)
+				out.WriteString(tok.String())
+			}
+		case html.EndTagToken:
+			if droppedTags[tok.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 || textOnly {
+				continue
+			}
+			if _, ok := allowedTags[tok.Data]; ok {
+				out.WriteString(tok.String())
+			}
+		}
+	}
+}
+
+// PlainText возвращает текст без HTML разметки
+func PlainText(src string) string {
+	return collapseSpaces(sanitizeHTML(src, true))
+}
+
+func filterAttrs(attrs []html.Attribute, allowed []string) []html.Attribute {
+	var res []html.Attribute
+	for _, a := range attrs {
+		for _, name := range allowed {
+			if a.Key != name {
+				continue
+			}
+			if (name == "href" || name == "src") && !safeURL(a.Val) {
+				continue
+			}
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// safeURL допускает относительные ссылки и схемы http/https
+func safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || u.Scheme == "http" || u.Scheme == "https"
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ---- dedup ----
+
+// dedup отбрасывает элементы, уже встречавшиеся по нормализованной ссылке или заголовку.
+// Ключи запоминаются в Commit, то есть только после записи поста в БД;
+// повторы внутри одной пачки отсекаются сразу.
+type dedup struct {
+	Keys []string `json:"keys"` // "link" и/или "title"
+	Size int      `json:"size"` // сколько ключей помнить
+
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+}
+
+func newDedup(params json.RawMessage) (Stage, error) {
+	s := &dedup{Keys: []string{"link"}, Size: 10000}
+	if err := DecodeParams(params, s); err != nil {
+		return nil, err
+	}
+	s.seen = make(map[string]bool, s.Size)
+	return s, nil
+}
+
+func (s *dedup) Name() string { return "dedup" }
+
+func (s *dedup) Process(item *Item) error {
+	keys := s.keys(item.Post)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// seen меняется только в Commit, поэтому dry-run видит те же ключи, что и боевой прогон
+	for _, k := range keys {
+		if s.seen[k] {
+			return Duplicate("duplicate %s", k)
+		}
+		if item.batch[k] {
+			return Reject("duplicate %s in batch", k)
+		}
+	}
+	if item.batch != nil {
+		for _, k := range keys {
+			item.batch[k] = true
+		}
+	}
+	return nil
+}
+
+// Commit запоминает ключи сохраненных постов
+func (s *dedup) Commit(posts []postgres.Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range posts {
+		for _, k := range s.keys(p) {
+			if !s.seen[k] {
+				s.remember(k)
+			}
+		}
+	}
+}
+
+// keys возвращает ключи поста для сравнения
+func (s *dedup) keys(p postgres.Post) []string {
+	var keys []string
+	for _, k := range s.Keys {
+		switch k {
+		case "link":
+			keys = append(keys, "link:"+normalizeLink(p.Link))
+		case "title":
+			keys = append(keys, "title:"+strings.ToLower(collapseSpaces(p.Title)))
+		}
+	}
+	return keys
+}
+
+// remember запоминает ключ, вытесняя самый старый при переполнении
+func (s *dedup) remember(key string) {
+	s.seen[key] = true
+	s.order = append(s.order, key)
+	if len(s.order) > s.Size {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// normalizeLink убирает из ссылки фрагмент, utm-метки и завершающий слэш
+func normalizeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+	u.Fragment = ""
+	u.Host = strings.ToLower(u.Host)
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	q := u.Query()
+	for k := range q {
+		if strings.HasPrefix(k, "utm_") {
+			q.Del(k)
+		}
+	}
+	u.RawQuery = q.Encode()
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
+
+// ---- tags ----
+
+// staticTags добавляет всем постам ленты заданные теги
+type staticTags struct {
+	Tags []string `json:"tags"`
+	Kind string   `json:"kind"` // вид тегов, по умолчанию "topic"
+}
+
+func newStaticTags(params json.RawMessage) (Stage, error) {
+	s := &staticTags{Kind: "topic"}
+	return s, DecodeParams(params, s)
+}
+
+func (s *staticTags) Name() string { return "tags" }
+
+func (s *staticTags) Process(item *Item) error {
+	for _, name := range s.Tags {
+		item.Post.Tags = append(item.Post.Tags, postgres.Tag{Name: name, Kind: s.Kind})
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"news/pkg/tracing"
+)
+
+// ExpiredPosts возвращает до limit постов, вышедших за пределы политики хранения:
+// опубликованных раньше before (если before не нулевое) или не входящих в maxPerSource
+// последних постов своей ленты (если maxPerSource > 0). Теги загружаются вместе с постами.
+func (s *NewsDb) ExpiredPosts(ctx context.Context, before time.Time, maxPerSource int, limit int) (_ []Post, err error) {
+	ctx, span := startSpan(ctx, "ExpiredPosts")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.Db.Query(ctx, `
+		SELECT id, title, content, summary, pub_time, link, source, lang
+		FROM (
+			SELECT *, row_number() OVER (PARTITION BY source ORDER BY pub_time DESC, id DESC) AS rn
+			FROM posts
+		) ranked
+		WHERE ($1::timestamptz IS NOT NULL AND pub_time < $1)
+		   OR ($2 > 0 AND rn > $2)
+		ORDER BY id
+		LIMIT $3`, nullTime(before), maxPerSource, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска устаревших постов: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var p Post
+		var pubTime time.Time
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Summary, &pubTime, &p.Link, &p.Source, &p.Lang); err != nil {
+			return nil, err
+		}
+		p.PubTime = pubTime.Unix()
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := s.attachTags(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// ArchivePosts переносит посты в posts_archive и удаляет их из posts в одной транзакции.
+// Если exportFile не пуст, посты уже выгружены в этот файл и в архиве остается только
+// заголовок и ссылка, без текста.
+func (s *NewsDb) ArchivePosts(ctx context.Context, posts []Post, exportFile string) (err error) {
+	ctx, span := startSpan(ctx, "ArchivePosts")
+	defer func() { tracing.End(span, err) }()
+	tx, err := s.Db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, p := range posts {
+		tags, err := json.Marshal(p.Tags)
+		if err != nil {
+			return err
+		}
+		content, summary := p.Content, p.Summary
+		if exportFile != "" {
+			content = ""
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO posts_archive (id, title, content, summary, pub_time, link, source, lang, tags, export_file)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (id) DO NOTHING`,
+			p.ID, p.Title, content, summary, time.Unix(p.PubTime, 0), p.Link, p.Source, p.Lang, tags, exportFile)
+		if err != nil {
+			return fmt.Errorf("archive post %d: %w", p.ID, err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM posts WHERE id = $1`, p.ID); err != nil {
+			return fmt.Errorf("delete post %d: %w", p.ID, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// archivedPostByID ищет пост в архиве
+func (s *NewsDb) archivedPostByID(ctx context.Context, id int) (Post, error) {
+	var p Post
+	var pubTime time.Time
+	var tags []byte
+	err := s.Db.QueryRow(ctx, `
+		SELECT id, title, content, summary, pub_time, link, source, lang, tags
+		FROM posts_archive
+		WHERE id = $1`, id).Scan(&p.ID, &p.Title, &p.Content, &p.Summary, &pubTime, &p.Link, &p.Source, &p.Lang, &tags)
+	if err != nil {
+		return p, err
+	}
+	p.PubTime = pubTime.Unix()
+	p.Archived = true
+	if err := json.Unmarshal(tags, &p.Tags); err != nil {
+		return p, err
+	}
+	if p.Tags == nil {
+		p.Tags = []Tag{}
+	}
+	return p, nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
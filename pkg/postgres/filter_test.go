@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewsFilterWhere(t *testing.T) {
+	where, args := NewsFilter{}.where()
+	require.Empty(t, where)
+	require.Empty(t, args)
+
+	// Язык задан: запрос разбирается одной конфигурацией
+	where, args = NewsFilter{Search: "RTX 50%", Lang: "ru"}.where()
+	require.Contains(t, where, "plainto_tsquery('russian', $1)")
+	require.NotContains(t, where, "ts_config")
+	require.Contains(t, where, "content ILIKE $2")
+	require.Equal(t, []any{"RTX 50%", `%RTX 50\%%`, "ru"}, args)
+
+	// Без языка - все конфигурации сразу, чтобы работал индекс
+	where, args = NewsFilter{Search: "snake_case"}.where()
+	require.Equal(t, len(textSearchConfigs)+1, strings.Count(where, "plainto_tsquery("))
+	require.NotContains(t, where, "ts_config")
+	require.Equal(t, []any{"snake_case", `%snake\_case%`}, args)
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector снимает статистику пула соединений pgxpool при каждом сборе метрик
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	total, idle, acquired, constructing, max                     *prometheus.Desc
+	acquireCount, acquireDuration, emptyAcquire, canceledAcquire *prometheus.Desc
+}
+
+// Collector возвращает коллектор метрик пула соединений с БД
+func (s *NewsDb) Collector() prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		pool:            s.Db,
+		total:           desc("total_conns", "Все соединения пула."),
+		idle:            desc("idle_conns", "Свободные соединения."),
+		acquired:        desc("acquired_conns", "Занятые соединения."),
+		constructing:    desc("constructing_conns", "Соединения в процессе установки."),
+		max:             desc("max_conns", "Максимальный размер пула."),
+		acquireCount:    desc("acquire_total", "Успешные получения соединения из пула."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Суммарное время ожидания соединения."),
+		emptyAcquire:    desc("empty_acquire_total", "Получения соединения, которым пришлось ждать свободного."),
+		canceledAcquire: desc("canceled_acquire_total", "Получения соединения, отмененные контекстом."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.total, c.idle, c.acquired, c.constructing, c.max,
+		c.acquireCount, c.acquireDuration, c.emptyAcquire, c.canceledAcquire} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v int32) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.total, s.TotalConns())
+	gauge(c.idle, s.IdleConns())
+	gauge(c.acquired, s.AcquiredConns())
+	gauge(c.constructing, s.ConstructingConns())
+	gauge(c.max, s.MaxConns())
+	counter(c.acquireCount, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.emptyAcquire, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquire, float64(s.CanceledAcquireCount()))
+}
@@ -2,9 +2,17 @@ package postgres
 
 import (
 	"context"
+	_ "embed"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"news/pkg/tracing"
+
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -29,6 +37,18 @@ func NewNewsDb(connectionString string) (*NewsDb, error) {
 	return &newsDB, nil
 }
 
+//go:embed schema.sql
+var schema string
+
+// Migrate создает недостающие таблицы, колонки и индексы
+func (s *NewsDb) Migrate() error {
+	_, err := s.Db.Exec(context.Background(), schema)
+	if err != nil {
+		return fmt.Errorf("не удалось применить схему: %w", err)
+	}
+	return nil
+}
+
 // Структуры
 // Структура для пагинации
 type Pagination struct {
@@ -47,20 +67,110 @@ type Post struct {
 	ID      int    `json:"id"`
 	Title   string `json:"title"`
 	Content string `json:"content"`
+	Summary string `json:"summary"`
 	PubTime int64  `json:"pub_time"`
 	Link    string `json:"link"`
+	Source  string `json:"source"`
+	Lang    string `json:"lang"`
+	Tags    []Tag  `json:"tags"`
+
+	Archived bool `json:"archived,omitempty"` // пост перенесен в архив политикой хранения
+}
+
+// Конфигурации полнотекстового поиска PostgreSQL по языкам поста
+var textSearchConfigs = map[string]string{
+	"ru": "russian",
+	"en": "english",
+	"de": "german",
+}
+
+// searchConfigs - все конфигурации поиска в постоянном порядке
+func searchConfigs() []string {
+	configs := []string{"simple"}
+	for _, cfg := range textSearchConfigs {
+		configs = append(configs, cfg)
+	}
+	sort.Strings(configs[1:])
+	return configs
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// TextSearchConfig возвращает конфигурацию поиска для языка; для неизвестных - simple
+func TextSearchConfig(lang string) string {
+	if cfg, ok := textSearchConfigs[lang]; ok {
+		return cfg
+	}
+	return "simple"
+}
+
+// Фильтр списка новостей. Пустые поля не ограничивают выборку.
+type NewsFilter struct {
+	Search string // поисковый запрос
+	Tag    string // тег без учета регистра
+	Lang   string // язык поста
+	Source string // URL ленты
+}
+
+// where строит условие WHERE и аргументы запроса для фильтра
+func (f NewsFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if f.Search != "" {
+		// Полнотекстовый поиск с конфигурацией языка из фильтра, а без него - по всем
+		// конфигурациям сразу. Подстрока в заголовке и тексте находит то, что не
+		// покрывается словоформами (например, названия моделей). Каждую ветку OR
+		// обслуживает свой GIN-индекс (search_vector и триграммы pg_trgm по title и
+		// content), поэтому планировщик объединяет их через BitmapOr. Триграммный
+		// индекс не помогает для запросов короче трех символов - они читают всю таблицу.
+		configs := []string{TextSearchConfig(f.Lang)}
+		if f.Lang == "" {
+			configs = searchConfigs()
+		}
+		args = append(args, f.Search)
+		query := len(args)
+		var tsQueries []string
+		for _, cfg := range configs {
+			tsQueries = append(tsQueries, fmt.Sprintf("plainto_tsquery('%s', $%d)", cfg, query))
+		}
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+		conds = append(conds, fmt.Sprintf(
+			`(search_vector @@ (%s) OR title ILIKE $%d ESCAPE '\' OR content ILIKE $%d ESCAPE '\')`,
+			strings.Join(tsQueries, " || "), len(args), len(args)))
+	}
+	if f.Lang != "" {
+		args = append(args, f.Lang)
+		conds = append(conds, fmt.Sprintf("lang = $%d", len(args)))
+	}
+	if f.Source != "" {
+		args = append(args, f.Source)
+		conds = append(conds, fmt.Sprintf("source = $%d", len(args)))
+	}
+	if f.Tag != "" {
+		args = append(args, f.Tag)
+		conds = append(conds, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM post_tags t WHERE t.post_id = posts.id AND lower(t.tag) = lower($%d))", len(args)))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
 }
 
 // Универсальный метод: Поиск + Пагинация
-func (s *NewsDb) GetNews(search string, page int) (NewsResponse, error) {
+func (s *NewsDb) GetNews(ctx context.Context, filter NewsFilter, page int) (_ NewsResponse, err error) {
+	ctx, span := startSpan(ctx, "GetNews")
+	defer func() { tracing.End(span, err) }()
 	const itemsPerPage = 15 // Фиксировано по ТЗ
 	offset := (page - 1) * itemsPerPage
+	where, args := filter.where()
 
 	// 1. Сначала считаем общее количество новостей, подходящих под поиск
 	// Это нужно, чтобы вычислить количество страниц (TotalPages)
 	var totalItems int
-	countQuery := "SELECT count(*) FROM posts WHERE title ILIKE $1 OR content ILIKE $1"
-	err := s.Db.QueryRow(context.Background(), countQuery, "%"+search+"%").Scan(&totalItems)
+	countQuery := "SELECT count(*) FROM posts " + where
+	err = s.Db.QueryRow(ctx, countQuery, args...).Scan(&totalItems)
 	if err != nil {
 		return NewsResponse{}, fmt.Errorf("ошибка подсчета строк: %w", err)
 	}
@@ -73,13 +183,13 @@ func (s *NewsDb) GetNews(search string, page int) (NewsResponse, error) {
 	}
 
 	// 2. Получаем сами новости с использованием LIMIT (сколько взять) и OFFSET (сколько пропустить)
-	rows, err := s.Db.Query(context.Background(), `
-		SELECT id, title, content, pub_time, link 
-		FROM posts 
-		WHERE title ILIKE $1 OR content ILIKE $1
+	n := len(args)
+	rows, err := s.Db.Query(ctx, `
+		SELECT id, title, content, summary, pub_time, link, source, lang
+		FROM posts `+where+`
 		ORDER BY pub_time DESC 
-		LIMIT $2 OFFSET $3`,
-		"%"+search+"%", itemsPerPage, offset)
+		LIMIT $`+strconv.Itoa(n+1)+` OFFSET $`+strconv.Itoa(n+2),
+		append(args, itemsPerPage, offset)...)
 	if err != nil {
 		return NewsResponse{}, fmt.Errorf("ошибка получения данных: %w", err)
 	}
@@ -89,7 +199,7 @@ func (s *NewsDb) GetNews(search string, page int) (NewsResponse, error) {
 	for rows.Next() {
 		var p Post
 		var pubTime time.Time
-		err := rows.Scan(&p.ID, &p.Title, &p.Content, &pubTime, &p.Link)
+		err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Summary, &pubTime, &p.Link, &p.Source, &p.Lang)
 		if err != nil {
 			return NewsResponse{}, err
 		}
@@ -97,10 +207,18 @@ func (s *NewsDb) GetNews(search string, page int) (NewsResponse, error) {
 		posts = append(posts, p)
 	}
 
+	if err := rows.Err(); err != nil {
+		return NewsResponse{}, err
+	}
+	rows.Close()
+
 	// Если новостей нет, возвращаем пустой массив, а не nil (чтобы в JSON было [])
 	if posts == nil {
 		posts = []Post{}
 	}
+	if err := s.attachTags(ctx, posts); err != nil {
+		return NewsResponse{}, err
+	}
 
 	return NewsResponse{
 		News: posts,
@@ -112,74 +230,236 @@ func (s *NewsDb) GetNews(search string, page int) (NewsResponse, error) {
 	}, nil
 }
 
-// AddPosts добавляет новые посты в БД
-func (s *NewsDb) AddPosts(adPosts []Post) error {
-	ctx := context.Background()
+// BackfillLang определяет язык постов, сохраненных до появления detect_lang, и
+// перестраивает их поисковый вектор. Посты, язык которых detect не определил
+// (вернул пустую строку), остаются как есть. Возвращает число обновленных постов.
+func (s *NewsDb) BackfillLang(ctx context.Context, detect func(title, content string) string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "BackfillLang")
+	defer func() { tracing.End(span, err) }()
 
-	for _, post := range adPosts {
-		_, err := s.Db.Exec(ctx, `
-            INSERT INTO posts (title, content, pub_time, link)
-            VALUES ($1, $2, $3, $4)
+	const batchSize = 500
+	updated, lastID := 0, 0
+	for {
+		rows, err := s.Db.Query(ctx, `
+			SELECT id, title, content FROM posts
+			WHERE lang = '' AND id > $1
+			ORDER BY id
+			LIMIT $2`, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		var posts []Post
+		for rows.Next() {
+			var p Post
+			if err := rows.Scan(&p.ID, &p.Title, &p.Content); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			posts = append(posts, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+
+		for _, p := range posts {
+			lastID = p.ID
+			lang := detect(p.Title, p.Content)
+			if lang == "" {
+				continue
+			}
+			_, err := s.Db.Exec(ctx, `
+				UPDATE posts
+				SET lang = $2, ts_config = $3::regconfig, updated_at = now(),
+				    search_vector = setweight(to_tsvector($3::regconfig, title), 'A') || setweight(to_tsvector($3::regconfig, content), 'B')
+				WHERE id = $1`, p.ID, lang, TextSearchConfig(lang))
+			if err != nil {
+				return updated, fmt.Errorf("update post %d: %w", p.ID, err)
+			}
+			updated++
+		}
+		if len(posts) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// AddPosts добавляет новые посты и их теги в БД.
+// ID вставленных постов записываются в adPosts; у уже существующих ID остается 0.
+func (s *NewsDb) AddPosts(ctx context.Context, adPosts []Post) (err error) {
+	ctx, span := startSpan(ctx, "AddPosts")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := s.Db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for i, post := range adPosts {
+		err := tx.QueryRow(ctx, `
+            INSERT INTO posts (title, content, summary, pub_time, link, source, lang, ts_config, search_vector)
+            SELECT $1, $2, $3, $4, $5, $6, $7, $8::regconfig,
+                   setweight(to_tsvector($8::regconfig, $1), 'A') || setweight(to_tsvector($8::regconfig, $2), 'B')
+            WHERE NOT EXISTS (SELECT 1 FROM posts_archive WHERE link = $5) -- архивные посты не возвращаются
             ON CONFLICT (link) DO NOTHING
-        `, post.Title, post.Content, time.Unix(post.PubTime, 0), post.Link)
+            RETURNING id
+        `, post.Title, post.Content, post.Summary, time.Unix(post.PubTime, 0), post.Link, post.Source,
+			post.Lang, TextSearchConfig(post.Lang)).Scan(&adPosts[i].ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // такой пост уже есть
+		}
 		if err != nil {
 			return fmt.Errorf("insert post: %w", err)
 		}
+
+		for _, tag := range post.Tags {
+			_, err := tx.Exec(ctx, `
+                INSERT INTO post_tags (post_id, tag, kind)
+                VALUES ($1, $2, $3)
+                ON CONFLICT DO NOTHING
+            `, adPosts[i].ID, tag.Name, tag.Kind)
+			if err != nil {
+				return fmt.Errorf("insert tag: %w", err)
+			}
+		}
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
-// // Posts возвращает список постов
-// func (s *NewsDb) Posts(n int) ([]Post, error) {
-// 	rows, err := s.Db.Query(context.Background(), `
-//         SELECT id, title, content, pub_time, link
-//         FROM posts
-//         ORDER BY pub_time DESC
-//         LIMIT $1
-//     `, n,
-// 	)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	defer rows.Close()
+// NewsVersion возвращает отпечаток набора постов под фильтром: он меняется при добавлении,
+// архивации и изменении постов (updated_at). Запрос дешевле выборки страницы и нужен для ETag.
+func (s *NewsDb) NewsVersion(ctx context.Context, filter NewsFilter) (_ string, err error) {
+	ctx, span := startSpan(ctx, "NewsVersion")
+	defer func() { tracing.End(span, err) }()
+	where, args := filter.where()
+	var count, maxID int64
+	var maxPub, maxUpdated *time.Time
+	err = s.Db.QueryRow(ctx,
+		"SELECT count(*), coalesce(max(id), 0), max(pub_time), max(updated_at) FROM posts "+where,
+		args...).Scan(&count, &maxID, &maxPub, &maxUpdated)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения версии: %w", err)
+	}
+	var pub, updated int64
+	if maxPub != nil {
+		pub = maxPub.Unix()
+	}
+	if maxUpdated != nil {
+		updated = maxUpdated.UnixMicro()
+	}
+	return fmt.Sprintf("%d-%d-%d-%d", count, maxID, pub, updated), nil
+}
 
-// 	var posts []Post
-// 	for rows.Next() {
-// 		var t Post
-// 		var pubTime time.Time
+// LatestPosts возвращает n последних постов, подходящих под фильтр, вместе с тегами
+func (s *NewsDb) LatestPosts(ctx context.Context, filter NewsFilter, n int) (_ []Post, err error) {
+	ctx, span := startSpan(ctx, "LatestPosts")
+	defer func() { tracing.End(span, err) }()
+	where, args := filter.where()
+	rows, err := s.Db.Query(ctx, `
+		SELECT id, title, content, summary, pub_time, link, source, lang
+		FROM posts `+where+`
+		ORDER BY pub_time DESC, id DESC
+		LIMIT $`+strconv.Itoa(len(args)+1),
+		append(args, n)...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения данных: %w", err)
+	}
+	defer rows.Close()
 
-// 		err = rows.Scan(
-// 			&t.ID,
-// 			&t.Title,
-// 			&t.Content,
-// 			&pubTime,
-// 			&t.Link,
-// 		)
-// 		if err != nil {
-// 			return nil, err
-// 		}
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		var pubTime time.Time
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Summary, &pubTime, &p.Link, &p.Source, &p.Lang); err != nil {
+			return nil, err
+		}
+		p.PubTime = pubTime.Unix()
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-// 		t.PubTime = pubTime.Unix()
+	if err := s.attachTags(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
 
-// 		posts = append(posts, t)
-// 	}
-// 	return posts, rows.Err()
-// }
+// Posts возвращает список постов
+func (s *NewsDb) Posts(ctx context.Context, n int) (_ []Post, err error) {
+	ctx, span := startSpan(ctx, "Posts")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.Db.Query(ctx, `
+        SELECT id, title, content, summary, pub_time, link, source, lang
+        FROM posts
+        ORDER BY pub_time DESC
+        LIMIT $1
+    `, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var t Post
+		var pubTime time.Time
+
+		err = rows.Scan(
+			&t.ID,
+			&t.Title,
+			&t.Content,
+			&t.Summary,
+			&pubTime,
+			&t.Link,
+			&t.Source,
+			&t.Lang,
+		)
+		if err != nil {
+			return nil, err
+		}
 
-// PostByID возвращает одну новость по её ID
-func (s *NewsDb) PostByID(id int) (Post, error) {
+		t.PubTime = pubTime.Unix()
+
+		posts = append(posts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// PostByID возвращает одну новость по её ID, в том числе из архива
+func (s *NewsDb) PostByID(ctx context.Context, id int) (_ Post, err error) {
+	ctx, span := startSpan(ctx, "PostByID")
+	defer func() { tracing.End(span, err) }()
 	var p Post
 	var pubTime time.Time
-	err := s.Db.QueryRow(context.Background(), `
-    SELECT id, title, content, pub_time, link
+	err = s.Db.QueryRow(ctx, `
+    SELECT id, title, content, summary, pub_time, link, source, lang
     FROM posts
     WHERE id = $1
-`, id).Scan(&p.ID, &p.Title, &p.Content, &pubTime, &p.Link)
+`, id).Scan(&p.ID, &p.Title, &p.Content, &p.Summary, &pubTime, &p.Link, &p.Source, &p.Lang)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.archivedPostByID(ctx, id)
+	}
 	if err != nil {
 		return p, err
 	}
 	p.PubTime = pubTime.Unix()
-	return p, nil
+
+	posts := []Post{p}
+	if err := s.attachTags(ctx, posts); err != nil {
+		return p, err
+	}
+	return posts[0], nil
 }
 
 // func (s *NewsDb) SearchPosts(query string) ([]Post, error) {
@@ -209,6 +489,11 @@ func (s *NewsDb) PostByID(id int) (Post, error) {
 // 	return posts, nil
 // }
 
+// Ping проверяет соединение с БД
+func (s *NewsDb) Ping(ctx context.Context) error {
+	return s.Db.Ping(ctx)
+}
+
 // Close закрывает соединение с БД
 func (s *NewsDb) Close() {
 	s.Db.Close()
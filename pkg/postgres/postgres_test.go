@@ -46,7 +46,7 @@ func TestNewsDb(t *testing.T) {
 		},
 	}
 
-	err = newsDB.AddPosts(testPosts)
+	err = newsDB.AddPosts(context.Background(), testPosts)
 	if err != nil {
 		t.Fatalf("ошибка при добавлении постов: %v", err)
 	}
@@ -71,3 +71,4 @@ func TestNewsDb(t *testing.T) {
 // 		t.Fatalf("тестовые посты не найдены в базе")
 // 	}
 // }
+}
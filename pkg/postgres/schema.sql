@@ -0,0 +1,59 @@
+-- Схема БД новостей. Все изменения идемпотентны и применяются при старте сервиса.
+
+CREATE TABLE IF NOT EXISTS posts (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    pub_time TIMESTAMPTZ NOT NULL,
+    link TEXT NOT NULL UNIQUE
+);
+
+-- Лента, из которой получен пост
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS posts_source_idx ON posts (source);
+
+-- Теги постов: ключевые слова и именованные сущности
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'keyword',
+    PRIMARY KEY (post_id, tag)
+);
+CREATE INDEX IF NOT EXISTS post_tags_lower_tag_idx ON post_tags (lower(tag));
+
+-- Язык поста и полнотекстовый индекс с конфигурацией этого языка
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS lang TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS ts_config REGCONFIG NOT NULL DEFAULT 'simple';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+UPDATE posts
+SET search_vector = setweight(to_tsvector(ts_config, title), 'A') || setweight(to_tsvector(ts_config, content), 'B')
+WHERE search_vector IS NULL;
+CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON posts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS posts_lang_idx ON posts (lang);
+
+-- Триграммные индексы для поиска подстроки (ILIKE '%...%') в заголовке и тексте
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS posts_title_trgm_idx ON posts USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS posts_content_trgm_idx ON posts USING GIN (content gin_trgm_ops);
+
+-- Экстрактивная аннотация поста
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS summary TEXT NOT NULL DEFAULT '';
+
+-- Время последнего изменения поста после вставки (язык, теги); входит в ETag списка
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- Архив постов, удаленных политикой хранения. Строка остается и при экспорте в файл
+-- (без текста), чтобы ссылки комментариев на news_id продолжали разрешаться.
+CREATE TABLE IF NOT EXISTS posts_archive (
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    pub_time TIMESTAMPTZ NOT NULL,
+    link TEXT NOT NULL UNIQUE,
+    source TEXT NOT NULL DEFAULT '',
+    lang TEXT NOT NULL DEFAULT '',
+    tags JSONB NOT NULL DEFAULT '[]',
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    export_file TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"news/pkg/tracing"
+)
+
+// Тег поста
+type Tag struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // keyword, company, person, place, topic
+}
+
+// Тег и количество постов с ним
+type TagCount struct {
+	Tag
+	Count int `json:"count"`
+}
+
+// Tags возвращает самые частые теги. kind ограничивает вид тегов, если не пуст.
+func (s *NewsDb) Tags(ctx context.Context, kind string, limit int) (_ []TagCount, err error) {
+	ctx, span := startSpan(ctx, "Tags")
+	defer func() { tracing.End(span, err) }()
+	rows, err := s.Db.Query(ctx, `
+		SELECT tag, kind, count(*) AS cnt
+		FROM post_tags
+		WHERE $1 = '' OR kind = $1
+		GROUP BY tag, kind
+		ORDER BY cnt DESC, tag
+		LIMIT $2`, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения тегов: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Name, &t.Kind, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// AddTags добавляет теги существующему посту; уже заданные теги не меняются
+func (s *NewsDb) AddTags(ctx context.Context, postID int, tags []Tag) (err error) {
+	ctx, span := startSpan(ctx, "AddTags")
+	defer func() { tracing.End(span, err) }()
+	for _, tag := range tags {
+		_, err := s.Db.Exec(ctx, `
+			INSERT INTO post_tags (post_id, tag, kind)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`, postID, tag.Name, tag.Kind)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления тега: %w", err)
+		}
+	}
+	// Теги входят в список новостей, поэтому его версия должна измениться
+	_, err = s.Db.Exec(ctx, "UPDATE posts SET updated_at = now() WHERE id = $1", postID)
+	return err
+}
+
+// attachTags загружает теги для постов одним запросом
+func (s *NewsDb) attachTags(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]int, len(posts))
+	index := make(map[int]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+		index[p.ID] = i
+		posts[i].Tags = []Tag{}
+	}
+
+	rows, err := s.Db.Query(ctx, `
+		SELECT post_id, tag, kind
+		FROM post_tags
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, kind, tag`, ids)
+	if err != nil {
+		return fmt.Errorf("ошибка получения тегов: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var t Tag
+		if err := rows.Scan(&id, &t.Name, &t.Kind); err != nil {
+			return err
+		}
+		i := index[id]
+		posts[i].Tags = append(posts[i].Tags, t)
+	}
+	return rows.Err()
+}
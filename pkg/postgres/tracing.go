@@ -0,0 +1,18 @@
+package postgres
+
+import (
+	"context"
+
+	"news/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan начинает спан запроса к БД. Спан завершается через tracing.End.
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "news/pkg/postgres", "postgres."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, attribute.String("db.operation.name", op)))
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"news/pkg/tracing"
+)
+
+// Тег, частота которого растет
+type Trend struct {
+	Tag
+	Recent   int     `json:"recent"`   // постов с тегом в окне
+	Baseline int     `json:"baseline"` // постов с тегом в базовом периоде перед окном
+	Score    float64 `json:"score"`    // во сколько раз выросла частота, с поправкой на объем
+}
+
+// Параметры расчета трендов
+type TrendOptions struct {
+	Window   time.Duration // последний период
+	Baseline time.Duration // период перед окном, с которым идет сравнение
+	Kind     string        // вид тегов, пусто - все
+	MinCount int           // минимум постов в окне
+	Limit    int
+}
+
+// Trending сравнивает частоту тегов в последнем окне с базовым периодом перед ним.
+// Оценка - отношение частот в постах в час со сглаживанием, умноженное на логарифм
+// числа упоминаний, чтобы единичные всплески не вытесняли устойчивые темы.
+func (s *NewsDb) Trending(ctx context.Context, opts TrendOptions) (_ []Trend, err error) {
+	ctx, span := startSpan(ctx, "Trending")
+	defer func() { tracing.End(span, err) }()
+	now := time.Now()
+	windowStart := now.Add(-opts.Window)
+	baselineStart := windowStart.Add(-opts.Baseline)
+
+	rows, err := s.Db.Query(ctx, `
+		SELECT t.tag, t.kind,
+		       count(*) FILTER (WHERE p.pub_time >= $1) AS recent,
+		       count(*) FILTER (WHERE p.pub_time < $1) AS baseline
+		FROM post_tags t
+		JOIN posts p ON p.id = t.post_id
+		WHERE p.pub_time >= $2 AND ($3 = '' OR t.kind = $3)
+		GROUP BY t.tag, t.kind
+		HAVING count(*) FILTER (WHERE p.pub_time >= $1) > 0`,
+		windowStart, baselineStart, opts.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка расчета трендов: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []Trend
+	for rows.Next() {
+		var t Trend
+		if err := rows.Scan(&t.Name, &t.Kind, &t.Recent, &t.Baseline); err != nil {
+			return nil, err
+		}
+		counts = append(counts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rankTrends(counts, opts), nil
+}
+
+// rankTrends оценивает теги по числу упоминаний в окне и базовом периоде,
+// отбрасывает редкие (меньше MinCount в окне) и сортирует по убыванию оценки
+func rankTrends(counts []Trend, opts TrendOptions) []Trend {
+	windowHours := opts.Window.Hours()
+	baselineHours := opts.Baseline.Hours()
+	trends := []Trend{}
+	for _, t := range counts {
+		if t.Recent < opts.MinCount {
+			continue
+		}
+		recentRate := float64(t.Recent) / windowHours
+		baselineRate := (float64(t.Baseline) + 1) / baselineHours
+		t.Score = math.Round(recentRate/baselineRate*math.Log1p(float64(t.Recent))*100) / 100
+		trends = append(trends, t)
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Name < trends[j].Name
+	})
+	if opts.Limit > 0 && len(trends) > opts.Limit {
+		trends = trends[:opts.Limit]
+	}
+	return trends
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankTrends(t *testing.T) {
+	opts := TrendOptions{Window: 24 * time.Hour, Baseline: 7 * 24 * time.Hour, MinCount: 2}
+	counts := []Trend{
+		{Tag: Tag{Name: "AMD"}, Recent: 10, Baseline: 70},  // частота не изменилась
+		{Tag: Tag{Name: "Intel"}, Recent: 10, Baseline: 6}, // всплеск
+		{Tag: Tag{Name: "Apple"}, Recent: 1, Baseline: 0},  // меньше MinCount
+		{Tag: Tag{Name: "ARM"}, Recent: 3, Baseline: 0},
+	}
+
+	trends := rankTrends(counts, opts)
+	require.Len(t, trends, 3)
+	require.Equal(t, "ARM", trends[0].Name)
+	require.Equal(t, "Intel", trends[1].Name)
+	require.Equal(t, "AMD", trends[2].Name)
+
+	// Без упоминаний в базовом периоде сглаживание не дает делить на ноль: (3/24) / (1/168) * ln(4)
+	require.Equal(t, 29.11, trends[0].Score)
+	require.Equal(t, 23.98, trends[1].Score)
+	// Частота в окне почти равна базовой: (10/24) / (71/168) * ln(11)
+	require.Equal(t, 2.36, trends[2].Score)
+
+	opts.Limit = 1
+	require.Len(t, rankTrends(counts, opts), 1)
+
+	opts.MinCount = 100
+	require.Empty(t, rankTrends(counts, opts))
+}
@@ -0,0 +1,169 @@
+// Package retention периодически удаляет из ленты устаревшие посты, перенося их
+// в архивную таблицу или выгружая в gzip файлы JSON Lines.
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"news/pkg/postgres"
+	"news/pkg/tracing"
+)
+
+// Режимы архивации
+const (
+	ModeArchive = "archive" // посты целиком переносятся в posts_archive
+	ModeExport  = "export"  // посты выгружаются в файл, в posts_archive остается заголовок и ссылка
+)
+
+// Config политика хранения. Нулевые MaxAgeDays и MaxPerSource отключают соответствующее ограничение.
+type Config struct {
+	MaxAgeDays   int    `json:"max_age_days"`   // максимальный возраст поста в днях
+	MaxPerSource int    `json:"max_per_source"` // сколько последних постов хранить для каждой ленты
+	Mode         string `json:"mode"`           // archive или export
+	ExportDir    string `json:"export_dir"`     // каталог для файлов в режиме export
+	Interval     int    `json:"interval"`       // период запуска в минутах
+	BatchSize    int    `json:"batch_size"`     // постов за одну транзакцию
+}
+
+func (c Config) withDefaults() Config {
+	if c.Mode == "" {
+		c.Mode = ModeArchive
+	}
+	if c.ExportDir == "" {
+		c.ExportDir = "archive"
+	}
+	if c.Interval <= 0 {
+		c.Interval = 60
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	return c
+}
+
+// Enabled сообщает, задано ли хотя бы одно ограничение
+func (c Config) Enabled() bool {
+	return c.MaxAgeDays > 0 || c.MaxPerSource > 0
+}
+
+// Store - операции БД, нужные задаче
+type Store interface {
+	ExpiredPosts(ctx context.Context, before time.Time, maxPerSource int, limit int) ([]postgres.Post, error)
+	ArchivePosts(ctx context.Context, posts []postgres.Post, exportFile string) error
+}
+
+// Job - фоновая задача применения политики хранения
+type Job struct {
+	config Config
+	store  Store
+	now    func() time.Time
+}
+
+// New создает задачу
+func New(config Config, store Store) (*Job, error) {
+	config = config.withDefaults()
+	if config.Mode != ModeArchive && config.Mode != ModeExport {
+		return nil, fmt.Errorf("retention: unknown mode %q", config.Mode)
+	}
+	return &Job{config: config, store: store, now: time.Now}, nil
+}
+
+// Start запускает задачу сразу и затем с периодом Interval. Ошибки пишутся в журнал.
+func (j *Job) Start() {
+	if !j.config.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(j.config.Interval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		n, err := j.RunOnce(context.Background())
+		if err != nil {
+			log.Printf("Retention error: %v", err)
+		} else if n > 0 {
+			log.Printf("Retention: archived %d posts", n)
+		}
+		<-ticker.C
+	}
+}
+
+// RunOnce архивирует все устаревшие посты пачками и возвращает их число
+func (j *Job) RunOnce(ctx context.Context) (total int, err error) {
+	ctx, span := tracing.Start(ctx, "news/pkg/retention", "retention.RunOnce")
+	defer func() { tracing.End(span, err) }()
+
+	var before time.Time
+	if j.config.MaxAgeDays > 0 {
+		before = j.now().AddDate(0, 0, -j.config.MaxAgeDays)
+	}
+
+	for {
+		posts, err := j.store.ExpiredPosts(ctx, before, j.config.MaxPerSource, j.config.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(posts) == 0 {
+			return total, nil
+		}
+
+		exportFile := ""
+		if j.config.Mode == ModeExport {
+			exportFile, err = j.export(posts)
+			if err != nil {
+				return total, err
+			}
+		}
+		if err := j.store.ArchivePosts(ctx, posts, exportFile); err != nil {
+			return total, err
+		}
+		total += len(posts)
+		if len(posts) < j.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// export записывает посты в новый gzip файл JSON Lines и возвращает его имя.
+// Файл создается до удаления постов из БД, поэтому сбой не приводит к потере данных.
+func (j *Job) export(posts []postgres.Post) (string, error) {
+	if err := os.MkdirAll(j.config.ExportDir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("posts-%s-%d.jsonl.gz", j.now().UTC().Format("20060102-150405"), posts[0].ID)
+	path := filepath.Join(j.config.ExportDir, name)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if err := writeJSONL(f, posts); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return name, nil
+}
+
+func writeJSONL(f *os.File, posts []postgres.Post) error {
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, p := range posts {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,112 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore хранит посты в памяти
+type fakeStore struct {
+	posts    []postgres.Post
+	archived []postgres.Post
+	files    []string
+}
+
+func (s *fakeStore) ExpiredPosts(ctx context.Context, before time.Time, maxPerSource int, limit int) ([]postgres.Post, error) {
+	var res []postgres.Post
+	for _, p := range s.posts {
+		if !before.IsZero() && time.Unix(p.PubTime, 0).Before(before) && len(res) < limit {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeStore) ArchivePosts(ctx context.Context, posts []postgres.Post, exportFile string) error {
+	gone := make(map[int]bool)
+	for _, p := range posts {
+		gone[p.ID] = true
+	}
+	var rest []postgres.Post
+	for _, p := range s.posts {
+		if !gone[p.ID] {
+			rest = append(rest, p)
+		}
+	}
+	s.posts = rest
+	s.archived = append(s.archived, posts...)
+	s.files = append(s.files, exportFile)
+	return nil
+}
+
+func testStore(now time.Time) *fakeStore {
+	s := &fakeStore{}
+	for i := 1; i <= 5; i++ {
+		s.posts = append(s.posts, postgres.Post{
+			ID:      i,
+			Title:   "Новость",
+			Link:    "https://example.com/" + string(rune('a'+i)),
+			PubTime: now.AddDate(0, 0, -i*10).Unix(),
+		})
+	}
+	return s
+}
+
+func TestRunOnceArchive(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := testStore(now)
+	job, err := New(Config{MaxAgeDays: 25, BatchSize: 2}, store)
+	require.NoError(t, err)
+	job.now = func() time.Time { return now }
+
+	n, err := job.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Len(t, store.posts, 2)
+	require.Equal(t, []string{"", ""}, store.files)
+}
+
+func TestRunOnceExport(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := testStore(now)
+	dir := t.TempDir()
+	job, err := New(Config{MaxAgeDays: 35, Mode: ModeExport, ExportDir: dir}, store)
+	require.NoError(t, err)
+	job.now = func() time.Time { return now }
+
+	n, err := job.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, store.files, 1)
+
+	f, err := os.Open(filepath.Join(dir, store.files[0]))
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var ids []int
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		var p postgres.Post
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &p))
+		ids = append(ids, p.ID)
+	}
+	require.NoError(t, sc.Err())
+	require.Equal(t, []int{4, 5}, ids)
+}
+
+func TestNewUnknownMode(t *testing.T) {
+	_, err := New(Config{Mode: "drop"}, &fakeStore{})
+	require.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// FakeFetcher - реализация Fetcher для тестов, отдающая заранее заданные ответы.
+// Для неизвестных URL возвращается 404.
+type FakeFetcher struct {
+	mu        sync.Mutex
+	responses map[string]fakeResponse
+	calls     map[string]int
+}
+
+type fakeResponse struct {
+	body []byte
+	err  error
+}
+
+func NewFakeFetcher() *FakeFetcher {
+	return &FakeFetcher{
+		responses: make(map[string]fakeResponse),
+		calls:     make(map[string]int),
+	}
+}
+
+// Set задает тело ответа для url
+func (f *FakeFetcher) Set(url string, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[url] = fakeResponse{body: []byte(body)}
+}
+
+// SetError задает ошибку, которую вернет запрос к url
+func (f *FakeFetcher) SetError(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[url] = fakeResponse{err: err}
+}
+
+// Calls возвращает количество запросов к url
+func (f *FakeFetcher) Calls(url string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[url]
+}
+
+func (f *FakeFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[url]++
+
+	resp, ok := f.responses[url]
+	if !ok {
+		return nil, &StatusError{Code: http.StatusNotFound}
+	}
+	return resp.body, resp.err
+}
@@ -0,0 +1,243 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"news/pkg/tracing"
+)
+
+// Fetcher загружает содержимое по URL. Парсер получает ленты и robots.txt только через него,
+// что позволяет подменять сетевой слой в тестах.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// Значения по умолчанию для HTTPFetcher; длительности в секундах
+const (
+	DefaultConnectTimeout = 10
+	DefaultReadTimeout    = 30
+	DefaultMaxRetries     = 3
+	DefaultRetryBackoff   = 1
+	DefaultMaxRetryWait   = 120
+)
+
+// FetcherConfig параметры HTTP клиента. Длительности - целые числа секунд,
+// как в файле настроек; в time.Duration они переводятся в NewHTTPFetcher.
+type FetcherConfig struct {
+	UserAgent          string `json:"user_agent"`
+	MaxBodySize        int64  `json:"max_body_size"`
+	ConnectTimeout     int    `json:"connect_timeout"`     // установка соединения и TLS, в секундах
+	ReadTimeout        int    `json:"read_timeout"`        // ожидание и чтение ответа, в секундах
+	Proxy              string `json:"proxy"`               // URL прокси; пусто - из окружения, "direct" - без прокси
+	DisableCompression bool   `json:"disable_compression"` // не запрашивать gzip
+	MaxRetries         int    `json:"max_retries"`         // повторы при 429, 5xx и сетевых ошибках
+	RetryBackoff       int    `json:"retry_backoff"`       // базовая пауза экспоненциального backoff, в секундах
+	MaxRetryWait       int    `json:"max_retry_wait"`      // предел паузы, в том числе из Retry-After, в секундах
+}
+
+func (c FetcherConfig) withDefaults() FetcherConfig {
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxBodySize
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = DefaultConnectTimeout
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = DefaultReadTimeout
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultRetryBackoff
+	}
+	if c.MaxRetryWait <= 0 {
+		c.MaxRetryWait = DefaultMaxRetryWait
+	}
+	return c
+}
+
+// ErrTooLarge возвращается, если ответ сервера превышает MaxBodySize
+var ErrTooLarge = errors.New("response body too large")
+
+// StatusError - ответ сервера с кодом, отличным от 200
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration // значение заголовка Retry-After, если он был
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP status %d", e.Code)
+}
+
+// temporary сообщает, имеет ли смысл повторить запрос
+func (e *StatusError) temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// HTTPFetcher - реализация Fetcher по умолчанию с таймаутами, прокси и повторами
+type HTTPFetcher struct {
+	config       FetcherConfig
+	retryBackoff time.Duration
+	maxRetryWait time.Duration
+	client       *http.Client
+	sleep        func(ctx context.Context, d time.Duration) error
+}
+
+// NewHTTPFetcher создает HTTPFetcher. Ошибка возвращается при некорректном адресе прокси.
+func NewHTTPFetcher(config FetcherConfig) (*HTTPFetcher, error) {
+	config = config.withDefaults()
+
+	proxy := http.ProxyFromEnvironment
+	switch config.Proxy {
+	case "":
+	case "direct":
+		proxy = nil
+	default:
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", config.Proxy, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	connectTimeout := time.Duration(config.ConnectTimeout) * time.Second
+	readTimeout := time.Duration(config.ReadTimeout) * time.Second
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		DisableCompression:    config.DisableCompression,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &HTTPFetcher{
+		config:       config,
+		retryBackoff: time.Duration(config.RetryBackoff) * time.Second,
+		maxRetryWait: time.Duration(config.MaxRetryWait) * time.Second,
+		client: &http.Client{
+			Transport: tracing.Transport(transport), // спан и traceparent на каждый запрос
+			Timeout:   connectTimeout + readTimeout,
+		},
+		sleep: sleepContext,
+	}, nil
+}
+
+// Fetch загружает url, повторяя запрос при временных ошибках
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		body, err := f.fetchOnce(ctx, url)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		wait, retry := f.retryDelay(err, attempt)
+		if !retry || attempt >= f.config.MaxRetries {
+			return nil, lastErr
+		}
+		if err := f.sleep(ctx, wait); err != nil {
+			return nil, lastErr
+		}
+	}
+}
+
+// retryDelay решает, повторять ли запрос, и вычисляет паузу перед повтором
+func (f *HTTPFetcher) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		if !statusErr.temporary() {
+			return 0, false
+		}
+		if statusErr.RetryAfter > 0 {
+			// Сервер просит подождать дольше, чем мы готовы - не повторяем
+			return statusErr.RetryAfter, statusErr.RetryAfter <= f.maxRetryWait
+		}
+	case errors.Is(err, ErrTooLarge), errors.Is(err, context.Canceled):
+		return 0, false
+	}
+
+	backoff := f.retryBackoff << attempt
+	backoff += time.Duration(rand.Int63n(int64(backoff)/2 + 1)) // разброс, чтобы не долбить хост синхронно
+	if backoff > f.maxRetryWait {
+		backoff = f.maxRetryWait
+	}
+	return backoff, true
+}
+
+// fetchOnce выполняет одну попытку запроса
+func (f *HTTPFetcher) fetchOnce(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{
+			Code:       resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.config.MaxBodySize {
+		return nil, ErrTooLarge
+	}
+	return body, nil
+}
+
+// parseRetryAfter разбирает Retry-After в виде числа секунд или HTTP-даты
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,125 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestFetcher создает HTTPFetcher, который не спит между повторами, а запоминает паузы
+func newTestFetcher(t *testing.T, config FetcherConfig) (*HTTPFetcher, *[]time.Duration) {
+	f, err := NewHTTPFetcher(config)
+	require.NoError(t, err)
+	var waits []time.Duration
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return f, &waits
+}
+
+func TestHTTPFetcherRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	f, waits := newTestFetcher(t, FetcherConfig{MaxRetries: 3})
+	body, err := f.Fetch(context.Background(), srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(body))
+	require.Equal(t, 3, attempts)
+	require.Len(t, *waits, 2)
+	require.Equal(t, 7*time.Second, (*waits)[0])
+}
+
+func TestHTTPFetcherNoRetry(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/slow-down":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, strings.Repeat("x", 2048))
+		}
+	}))
+	defer srv.Close()
+
+	f, waits := newTestFetcher(t, FetcherConfig{MaxBodySize: 1024})
+
+	// 4xx не повторяются
+	_, err := f.Fetch(context.Background(), srv.URL+"/missing")
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusNotFound, statusErr.Code)
+
+	// Retry-After больше допустимого - сразу ошибка
+	_, err = f.Fetch(context.Background(), srv.URL+"/slow-down")
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, http.StatusServiceUnavailable, statusErr.Code)
+
+	// Слишком большой ответ
+	_, err = f.Fetch(context.Background(), srv.URL+"/big")
+	require.True(t, errors.Is(err, ErrTooLarge))
+
+	require.Equal(t, 3, attempts)
+	require.Empty(t, *waits)
+}
+
+func TestHTTPFetcherTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	f, _ := newTestFetcher(t, FetcherConfig{MaxRetries: -1})
+	f.client.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err := f.Fetch(context.Background(), srv.URL)
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	require.Zero(t, parseRetryAfter(""))
+	require.Zero(t, parseRetryAfter("soon"))
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	d := parseRetryAfter(date)
+	require.Greater(t, d, 50*time.Second)
+	require.LessOrEqual(t, d, time.Minute)
+}
+
+func TestHTTPFetcherDurations(t *testing.T) {
+	f, err := NewHTTPFetcher(FetcherConfig{ConnectTimeout: 5, ReadTimeout: 10, MaxRetryWait: 30})
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Second, f.client.Timeout)
+	require.Equal(t, 30*time.Second, f.maxRetryWait)
+	require.Equal(t, time.Second, f.retryBackoff) // по умолчанию
+}
@@ -0,0 +1,65 @@
+package rss
+
+import (
+	"sync"
+	"time"
+)
+
+// hostLimiter ограничивает число одновременных запросов к одному хосту
+// и выдерживает минимальную паузу между их началом.
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	delay time.Duration
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	sem  chan struct{}
+	mu   sync.Mutex
+	next time.Time // время, раньше которого нельзя начинать следующий запрос
+}
+
+func newHostLimiter(limit int, delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		delay: delay,
+		hosts: make(map[string]*hostState),
+	}
+}
+
+func (l *hostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st, ok := l.hosts[host]
+	if !ok {
+		st = &hostState{sem: make(chan struct{}, l.limit)}
+		l.hosts[host] = st
+	}
+	return st
+}
+
+// acquire блокируется, пока к хосту нельзя обратиться, и возвращает функцию освобождения.
+// extraDelay (например, Crawl-delay из robots.txt) используется, если он больше настроенного.
+func (l *hostLimiter) acquire(host string, extraDelay time.Duration) (release func()) {
+	st := l.state(host)
+	st.sem <- struct{}{}
+
+	delay := l.delay
+	if extraDelay > delay {
+		delay = extraDelay
+	}
+
+	st.mu.Lock()
+	start := time.Now()
+	if st.next.After(start) {
+		start = st.next
+	}
+	st.next = start.Add(delay)
+	st.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+
+	return func() { <-st.sem }
+}
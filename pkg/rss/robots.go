@@ -0,0 +1,208 @@
+package rss
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed возвращается, если robots.txt запрещает загрузку ленты
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+const (
+	robotsTTL      = 24 * time.Hour   // сколько хранить успешно загруженный robots.txt
+	robotsErrorTTL = 10 * time.Minute // сколько хранить результат неудачной загрузки
+)
+
+// robotsRule - одно правило Allow/Disallow
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{allow: allow, pattern: pattern, re: regexp.MustCompile(expr)}
+}
+
+// robotsRules - правила из robots.txt, относящиеся к нашему User-Agent
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool // robots.txt недоступен из-за ошибки сервера
+}
+
+// allowed проверяет путь по правилам: побеждает самое длинное совпадение, при равенстве - Allow
+func (r *robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// parseRobots разбирает robots.txt и выбирает группу для agent (или группу "*")
+func parseRobots(body []byte, agent string) *robotsRules {
+	token := strings.ToLower(agent)
+	if i := strings.IndexByte(token, '/'); i >= 0 {
+		token = token[:i]
+	}
+
+	var own, wildcard *robotsRules
+	var current []*robotsRules
+	inAgents := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			ua := strings.ToLower(value)
+			switch {
+			case ua == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case ua == token:
+				if own == nil {
+					own = &robotsRules{}
+				}
+				current = append(current, own)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue
+			}
+			for _, g := range current {
+				g.rules = append(g.rules, newRobotsRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			inAgents = false
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			for _, g := range current {
+				g.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	if own != nil {
+		return own
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+	return &robotsRules{}
+}
+
+// robotsCache загружает и кэширует robots.txt по хостам
+type robotsCache struct {
+	agent   string
+	fetcher Fetcher
+
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	rules   *robotsRules
+	expires time.Time
+}
+
+func newRobotsCache(agent string, fetcher Fetcher) *robotsCache {
+	return &robotsCache{
+		agent:   agent,
+		fetcher: fetcher,
+		entries: make(map[string]*robotsEntry),
+	}
+}
+
+// check сообщает, можно ли загружать u, и возвращает Crawl-delay хоста
+func (c *robotsCache) check(u *url.URL) (bool, time.Duration) {
+	rules := c.rules(u)
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path), rules.crawlDelay
+}
+
+func (c *robotsCache) rules(u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rules
+	}
+
+	rules, ttl := c.load(key + "/robots.txt")
+
+	c.mu.Lock()
+	c.entries[key] = &robotsEntry{rules: rules, expires: time.Now().Add(ttl)}
+	c.mu.Unlock()
+	return rules
+}
+
+// load загружает robots.txt. Согласно RFC 9309 ошибка 4xx означает отсутствие
+// ограничений, а недоступность сервера или 5xx - полный запрет.
+func (c *robotsCache) load(robotsURL string) (*robotsRules, time.Duration) {
+	body, err := c.fetcher.Fetch(context.Background(), robotsURL)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code >= 400 && statusErr.Code < 500 {
+			return &robotsRules{}, robotsTTL
+		}
+		return &robotsRules{disallowAll: true}, robotsErrorTTL
+	}
+	return parseRobots(body, c.agent), robotsTTL
+}
@@ -0,0 +1,49 @@
+package rss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := []byte(`
+# общие правила
+User-agent: *
+Disallow: /private/
+Crawl-delay: 2
+
+User-agent: NewsAggregatorBot
+User-agent: OtherBot
+Disallow: /
+Allow: /rss/
+Allow: /*.xml$
+Crawl-delay: 0.5
+`)
+
+	// Для нашего агента используется собственная группа
+	own := parseRobots(body, "NewsAggregatorBot/1.0")
+	require.True(t, own.allowed("/rss/news"))
+	require.True(t, own.allowed("/feeds/main.xml"))
+	require.False(t, own.allowed("/feeds/main.xml?x=1"))
+	require.False(t, own.allowed("/news/"))
+	require.Equal(t, 500*time.Millisecond, own.crawlDelay)
+
+	// Для остальных - группа "*"
+	other := parseRobots(body, "SomeCrawler/2.0")
+	require.True(t, other.allowed("/rss/news"))
+	require.False(t, other.allowed("/private/feed"))
+	require.Equal(t, 2*time.Second, other.crawlDelay)
+}
+
+func TestRobotsCacheStatusHandling(t *testing.T) {
+	fetcher := NewFakeFetcher()
+	cache := newRobotsCache(DefaultUserAgent, fetcher)
+	rules, _ := cache.load("http://example.com/robots.txt")
+	require.True(t, rules.allowed("/anything"))
+
+	fetcher.SetError("http://down.example.com/robots.txt", &StatusError{Code: 503})
+	rules, _ = cache.load("http://down.example.com/robots.txt")
+	require.False(t, rules.allowed("/anything"))
+}
@@ -1,11 +1,21 @@
 package rss
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"news/pkg/metrics"
+	"news/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RSS структуры для парсинга
@@ -21,54 +31,158 @@ type Channel struct {
 }
 
 type Item struct {
-	Title   string `xml:"title"`
-	Link    string `xml:"link"`
-	Сontent string `xml:"description"`
-	PubDate string `xml:"pubDate"`
-	Guid    string `xml:"guid"`
+	Title      string   `xml:"title"`
+	Link       string   `xml:"link"`
+	Сontent    string   `xml:"description"`
+	PubDate    string   `xml:"pubDate"`
+	Guid       string   `xml:"guid"`
+	Categories []string `xml:"category"`
+	Author     string   `xml:"author"`
+	Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"` // dc:creator, часто вместо author
 }
 
+// AuthorName возвращает автора элемента из author или dc:creator
+func (i Item) AuthorName() string {
+	if i.Author != "" {
+		return i.Author
+	}
+	return i.Creator
+}
+
+// Batch - элементы, полученные из одной ленты
+type Batch struct {
+	Source string // URL ленты
+	Items  []Item
+
+	// SpanContext - спан загрузки ленты; обработка пачки продолжает его трассу
+	SpanContext trace.SpanContext
+}
+
+// Значения по умолчанию для пула загрузки
+const (
+	DefaultWorkers         = 4
+	DefaultHostConcurrency = 1
+	DefaultUserAgent       = "NewsAggregatorBot/1.0"
+	DefaultMaxBodySize     = 5 << 20 // 5 МБ
+)
+
 // Config конфигурация RSS
 type Config struct {
 	URLs          []string      `json:"rss"`
 	RequestPeriod time.Duration `json:"request_period"`
+
+	// Параметры пула загрузки
+	Workers         int           `json:"workers"`          // количество воркеров
+	HostConcurrency int           `json:"host_concurrency"` // одновременных запросов к одному хосту
+	HostDelay       time.Duration `json:"host_delay"`       // минимальная пауза между запросами к хосту, в секундах
+	UserAgent       string        `json:"user_agent"`       // User-Agent для запросов и robots.txt
+	MaxBodySize     int64         `json:"max_body_size"`    // максимальный размер ответа, байт
+	IgnoreRobots    bool          `json:"ignore_robots"`    // не проверять robots.txt
+
+	// Таймауты, прокси и повторы HTTP клиента по умолчанию
+	HTTP FetcherConfig `json:"http"`
+}
+
+// withDefaults заполняет незаданные параметры значениями по умолчанию
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = DefaultWorkers
+	}
+	if c.HostConcurrency <= 0 {
+		c.HostConcurrency = DefaultHostConcurrency
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = DefaultUserAgent
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxBodySize
+	}
+	return c
 }
 
 // Parser для работы с RSS
 type Parser struct {
-	config Config
+	config  Config
+	fetcher Fetcher
+	hosts   *hostLimiter
+	robots  *robotsCache
+
+	jobs     chan string
+	mu       sync.Mutex      // защищает config.URLs, config.RequestPeriod, inFlight и added
+	inFlight map[string]bool // ленты, которые уже стоят в очереди или загружаются
+	added    []string        // ленты, добавленные Reload или не поместившиеся в очередь
+	changed  chan struct{}   // сигнал планировщику после Reload
+	lastRun  atomic.Int64    // время последнего обхода лент, Unix наносекунды
 }
 
-func NewParser(config Config) *Parser {
-	return &Parser{
-		config: config,
+// NewParser создает парсер. Если fetcher равен nil, используется HTTPFetcher
+// с параметрами из config.HTTP.
+func NewParser(config Config, fetcher Fetcher) (*Parser, error) {
+	config = config.withDefaults()
+	if fetcher == nil {
+		httpConfig := config.HTTP
+		if httpConfig.UserAgent == "" {
+			httpConfig.UserAgent = config.UserAgent
+		}
+		if httpConfig.MaxBodySize == 0 {
+			httpConfig.MaxBodySize = config.MaxBodySize
+		}
+		f, err := NewHTTPFetcher(httpConfig)
+		if err != nil {
+			return nil, err
+		}
+		fetcher = f
+	}
+
+	p := &Parser{
+		config:   config,
+		fetcher:  fetcher,
+		hosts:    newHostLimiter(config.HostConcurrency, config.HostDelay*time.Second),
+		inFlight: make(map[string]bool),
+		changed:  make(chan struct{}, 1),
 	}
+	if !config.IgnoreRobots {
+		p.robots = newRobotsCache(config.UserAgent, fetcher)
+	}
+	return p, nil
 }
 
 // ParseFeed парсит RSS по URL и возвращает результат через каналы
-func (p *Parser) ParseFeed(url string, postsChan chan<- []Item, errChan chan<- error) {
-	items, err := p.parseURL(url)
+func (p *Parser) ParseFeed(url string, postsChan chan<- Batch, errChan chan<- error) {
+	ctx, span := tracing.Start(context.Background(), "news/pkg/rss", "rss.ParseFeed",
+		trace.WithAttributes(attribute.String("rss.source", url)))
+	start := time.Now()
+	items, err := p.parseURL(ctx, url)
+	metrics.FeedFetchDuration.WithLabelValues(url).Observe(time.Since(start).Seconds())
+	tracing.End(span, err)
 	if err != nil {
+		metrics.FeedFetchErrors.WithLabelValues(url).Inc()
 		errChan <- fmt.Errorf("feed %s: %w", url, err)
 		return
 	}
 
-	postsChan <- items
+	postsChan <- Batch{Source: url, Items: items, SpanContext: span.SpanContext()}
 }
 
-// parseURL выполняет HTTP запрос и парсит RSS
-func (p *Parser) parseURL(url string) ([]Item, error) {
-	resp, err := http.Get(url)
+// parseURL проверяет robots.txt, соблюдает ограничения хоста и парсит RSS
+func (p *Parser) parseURL(ctx context.Context, rawURL string) ([]Item, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	delay := time.Duration(0)
+	if p.robots != nil {
+		allowed, crawlDelay := p.robots.check(u)
+		if !allowed {
+			return nil, ErrDisallowed
+		}
+		delay = crawlDelay
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	release := p.hosts.acquire(u.Host, delay)
+	body, err := p.fetcher.Fetch(ctx, rawURL)
+	release()
 	if err != nil {
 		return nil, err
 	}
@@ -82,25 +196,142 @@ func (p *Parser) parseURL(url string) ([]Item, error) {
 	return rss.Channel.Items, nil
 }
 
-// Start запускает периодический парсинг RSS лент
-func (p *Parser) Start(postsChan chan<- []Item, errChan chan<- error) {
-	ticker := time.NewTicker(p.config.RequestPeriod * time.Minute)
+// Start запускает пул воркеров и периодический парсинг RSS лент
+func (p *Parser) Start(postsChan chan<- Batch, errChan chan<- error) {
+	p.mu.Lock()
+	p.jobs = make(chan string, len(p.config.URLs))
+	p.added = nil
+	p.mu.Unlock()
+	for i := 0; i < p.config.Workers; i++ {
+		go p.worker(postsChan, errChan)
+	}
+
+	ticker := time.NewTicker(p.period())
 	defer ticker.Stop()
 
 	// Первоначальный парсинг
-	p.parseAllFeeds(postsChan, errChan)
+	p.parseAllFeeds(errChan)
 
 	for {
 		select {
 		case <-ticker.C:
-			p.parseAllFeeds(postsChan, errChan)
+			p.parseAllFeeds(errChan)
+		case <-p.changed:
+			p.mu.Lock()
+			added := p.added
+			p.added = nil
+			p.mu.Unlock()
+			ticker.Reset(p.period())
+			p.enqueue(added, errChan)
 		}
 	}
 }
 
-// parseAllFeeds парсит все RSS ленты
-func (p *Parser) parseAllFeeds(postsChan chan<- []Item, errChan chan<- error) {
+// Reload заменяет список лент и период опроса без перезапуска. Добавленные
+// ленты загружаются сразу, удаленные перестают опрашиваться со следующего обхода.
+// Нулевой период оставляет прежний.
+func (p *Parser) Reload(urls []string, period time.Duration) {
+	p.mu.Lock()
+	known := make(map[string]bool, len(p.config.URLs))
 	for _, url := range p.config.URLs {
-		go p.ParseFeed(url, postsChan, errChan)
+		known[url] = true
+	}
+	// Ожидающие загрузки ленты, которых нет в новом списке, больше не нужны
+	p.added = slices.DeleteFunc(p.added, func(url string) bool {
+		return !slices.Contains(urls, url)
+	})
+	for _, url := range urls {
+		if !known[url] && !slices.Contains(p.added, url) {
+			p.added = append(p.added, url)
+		}
+	}
+	p.config.URLs = append([]string(nil), urls...)
+	if period > 0 {
+		p.config.RequestPeriod = period
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// period - текущий период опроса лент
+func (p *Parser) period() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config.RequestPeriod * time.Minute
+}
+
+// Healthy проверяет, что планировщик опроса лент работает: последний обход
+// был не раньше двух периодов назад
+func (p *Parser) Healthy() error {
+	last := p.lastRun.Load()
+	if last == 0 {
+		return errors.New("feed scheduler is not started")
+	}
+	if since := time.Since(time.Unix(0, last)); since > 2*p.period() {
+		return fmt.Errorf("last feed run %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// worker обрабатывает ленты из очереди по одной
+func (p *Parser) worker(postsChan chan<- Batch, errChan chan<- error) {
+	for url := range p.jobs {
+		p.ParseFeed(url, postsChan, errChan)
+
+		p.mu.Lock()
+		delete(p.inFlight, url)
+		pending := len(p.added) > 0
+		p.mu.Unlock()
+
+		// Место в очереди освободилось: планировщик ставит в нее отложенные ленты
+		if pending {
+			select {
+			case p.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// parseAllFeeds ставит все RSS ленты в очередь.
+// Ленты, не обработанные с прошлого тика, повторно не добавляются.
+func (p *Parser) parseAllFeeds(errChan chan<- error) {
+	p.lastRun.Store(time.Now().UnixNano())
+	p.mu.Lock()
+	urls := p.config.URLs
+	p.mu.Unlock()
+	p.enqueue(urls, errChan)
+}
+
+// enqueue ставит ленты в очередь, пропуская те, что еще обрабатываются.
+// Очередь рассчитана на исходный список лент; после Reload он может вырасти,
+// поэтому лишние ленты не блокируют планировщик, а ждут освобождения воркера.
+func (p *Parser) enqueue(urls []string, errChan chan<- error) {
+	for _, url := range urls {
+		p.mu.Lock()
+		busy := p.inFlight[url]
+		if !busy {
+			p.inFlight[url] = true
+		}
+		p.mu.Unlock()
+
+		if busy {
+			errChan <- fmt.Errorf("feed %s: previous fetch still in progress, skipped", url)
+			continue
+		}
+		select {
+		case p.jobs <- url:
+		default:
+			p.mu.Lock()
+			delete(p.inFlight, url)
+			if !slices.Contains(p.added, url) {
+				p.added = append(p.added, url)
+			}
+			p.mu.Unlock()
+		}
 	}
 }
@@ -0,0 +1,210 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testFeed = `<?xml version="1.0"?>
+<rss><channel><title>t</title>
+<item><title>Item</title><link>https://example.com/1</link></item>
+</channel></rss>`
+
+func TestParserHostConcurrency(t *testing.T) {
+	var current, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.UserAgent() != DefaultUserAgent {
+			http.Error(w, "unexpected user agent", http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		fmt.Fprint(w, testFeed)
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for i := 0; i < 5; i++ {
+		urls = append(urls, fmt.Sprintf("%s/feed/%d", srv.URL, i))
+	}
+	p, err := NewParser(Config{URLs: urls, RequestPeriod: 1, Workers: 5, HostConcurrency: 1}, nil)
+	require.NoError(t, err)
+
+	postsChan := make(chan Batch)
+	errChan := make(chan error)
+	go p.Start(postsChan, errChan)
+
+	for range urls {
+		select {
+		case batch := <-postsChan:
+			require.Len(t, batch.Items, 1)
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("таймаут ожидания лент")
+		}
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&peak))
+}
+
+func TestParserWithFakeFetcher(t *testing.T) {
+	fetcher := NewFakeFetcher()
+	fetcher.Set("https://example.com/robots.txt", "User-agent: *\nDisallow: /blocked\n")
+	fetcher.Set("https://example.com/rss", testFeed)
+	fetcher.SetError("https://example.com/broken", &StatusError{Code: 500})
+
+	p, err := NewParser(Config{}, fetcher)
+	require.NoError(t, err)
+
+	items, err := p.parseURL(context.Background(), "https://example.com/rss")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "https://example.com/1", items[0].Link)
+
+	_, err = p.parseURL(context.Background(), "https://example.com/blocked/rss")
+	require.True(t, errors.Is(err, ErrDisallowed))
+	require.Equal(t, 0, fetcher.Calls("https://example.com/blocked/rss"))
+
+	_, err = p.parseURL(context.Background(), "https://example.com/broken")
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, 500, statusErr.Code)
+
+	// robots.txt загружается один раз на хост
+	require.Equal(t, 1, fetcher.Calls("https://example.com/robots.txt"))
+}
+
+func TestParserHealthy(t *testing.T) {
+	p, err := NewParser(Config{RequestPeriod: 5}, NewFakeFetcher())
+	require.NoError(t, err)
+	require.Error(t, p.Healthy())
+
+	p.jobs = make(chan string, 1)
+	p.parseAllFeeds(make(chan error, 1))
+	require.NoError(t, p.Healthy())
+
+	// Обход не выполнялся дольше двух периодов
+	p.lastRun.Store(time.Now().Add(-11 * time.Minute).UnixNano())
+	require.Error(t, p.Healthy())
+}
+
+func TestParserReload(t *testing.T) {
+	fetcher := NewFakeFetcher()
+	for _, u := range []string{"https://a.example/rss", "https://b.example/rss", "https://c.example/rss"} {
+		fetcher.Set(u, testFeed)
+	}
+	p, err := NewParser(Config{URLs: []string{"https://a.example/rss", "https://b.example/rss"}, RequestPeriod: 60, IgnoreRobots: true}, fetcher)
+	require.NoError(t, err)
+
+	postsChan := make(chan Batch)
+	errChan := make(chan error, 10)
+	go p.Start(postsChan, errChan)
+
+	sources := func(n int) map[string]bool {
+		got := make(map[string]bool)
+		for i := 0; i < n; i++ {
+			select {
+			case batch := <-postsChan:
+				got[batch.Source] = true
+			case err := <-errChan:
+				t.Fatal(err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("таймаут ожидания лент")
+			}
+		}
+		return got
+	}
+	require.Equal(t, map[string]bool{"https://a.example/rss": true, "https://b.example/rss": true}, sources(2))
+
+	// Добавленная лента загружается сразу, не дожидаясь периода
+	p.Reload([]string{"https://b.example/rss", "https://c.example/rss"}, 30)
+	require.Equal(t, map[string]bool{"https://c.example/rss": true}, sources(1))
+	require.Equal(t, 30*time.Minute, p.period())
+
+	// Удаленная лента больше не опрашивается
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.inFlight) == 0
+	}, time.Second, time.Millisecond)
+	p.parseAllFeeds(errChan)
+	require.Equal(t, map[string]bool{"https://b.example/rss": true, "https://c.example/rss": true}, sources(2))
+	require.Equal(t, 1, fetcher.Calls("https://a.example/rss"))
+}
+
+func TestParserReloadOverflowsQueue(t *testing.T) {
+	urls := []string{"https://a.example/rss", "https://b.example/rss", "https://c.example/rss", "https://d.example/rss"}
+	fetcher := NewFakeFetcher()
+	for _, u := range urls {
+		fetcher.Set(u, testFeed)
+	}
+	// Очередь рассчитана на одну ленту, воркер один
+	p, err := NewParser(Config{URLs: urls[:1], RequestPeriod: 60, Workers: 1, IgnoreRobots: true}, fetcher)
+	require.NoError(t, err)
+
+	postsChan := make(chan Batch)
+	errChan := make(chan error, 10)
+	go p.Start(postsChan, errChan)
+
+	state := func(f func() bool) func() bool {
+		return func() bool {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return f()
+		}
+	}
+	require.Eventually(t, state(func() bool { return p.inFlight[urls[0]] }), time.Second, time.Millisecond)
+
+	// Пока воркер ждет отправки первой пачки, добавленные ленты не помещаются в очередь
+	p.Reload(urls, 0)
+	require.Eventually(t, state(func() bool { return len(p.added) > 0 }), time.Second, time.Millisecond)
+
+	got := make(map[string]bool)
+	for len(got) < len(urls) {
+		select {
+		case batch := <-postsChan:
+			got[batch.Source] = true
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("таймаут ожидания лент")
+		}
+	}
+	for _, u := range urls {
+		require.Equal(t, 1, fetcher.Calls(u), u)
+	}
+}
+
+func TestParserReloadDropsRemovedPending(t *testing.T) {
+	urls := []string{"https://a.example/rss", "https://b.example/rss", "https://c.example/rss"}
+	p, err := NewParser(Config{URLs: urls[:1], RequestPeriod: 60, IgnoreRobots: true}, NewFakeFetcher())
+	require.NoError(t, err)
+
+	// Планировщик не запущен, поэтому добавленные ленты остаются в ожидании
+	p.Reload(urls, 0)
+	require.Equal(t, urls[1:], p.added)
+
+	// Удаленная до загрузки лента не загружается
+	p.Reload(urls[:2], 0)
+	require.Equal(t, urls[1:2], p.added)
+}
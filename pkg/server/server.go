@@ -0,0 +1,76 @@
+// Package server запускает HTTP сервер сервиса с ограничениями времени на
+// соединение и корректной остановкой по SIGINT/SIGTERM.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Ограничения соединения. Они защищают от медленных клиентов, которые держат
+// соединение открытым; потоковые маршруты снимают сроки чтения и записи сами
+// (см. middleware.Timeout).
+const (
+	ReadHeaderTimeout = 5 * time.Second
+	ReadTimeout       = 15 * time.Second
+	WriteTimeout      = 30 * time.Second
+	IdleTimeout       = 2 * time.Minute
+	ShutdownTimeout   = 15 * time.Second
+)
+
+// New создает сервер с ограничениями времени по умолчанию
+func New(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       ReadTimeout,
+		WriteTimeout:      WriteTimeout,
+		IdleTimeout:       IdleTimeout,
+	}
+}
+
+// Run принимает соединения до SIGINT или SIGTERM, затем дожидается завершения
+// текущих запросов не дольше ShutdownTimeout. Оставшиеся соединения (например,
+// потоки SSE) закрываются принудительно.
+func Run(srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, srv, ln)
+}
+
+// serve обслуживает ln до отмены ctx
+func serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Остановка сервера %s", srv.Addr)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = srv.Close()
+	}
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	srv := New(":8080", http.NotFoundHandler())
+	require.Equal(t, ReadHeaderTimeout, srv.ReadHeaderTimeout)
+	require.Equal(t, ReadTimeout, srv.ReadTimeout)
+	require.Equal(t, WriteTimeout, srv.WriteTimeout)
+	require.Equal(t, IdleTimeout, srv.IdleTimeout)
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	srv := New("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, srv, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		resc <- result{string(b), err}
+	}()
+
+	// Остановка во время запроса: запрос дорабатывает, новые не принимаются
+	<-started
+	cancel()
+	res := <-resc
+	require.NoError(t, res.err)
+	require.Equal(t, "done", res.body)
+	require.NoError(t, <-served)
+
+	_, err = http.Get("http://" + ln.Addr().String())
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Heartbeat - период комментариев-пингов, не дающих прокси закрыть соединение
+var Heartbeat = 15 * time.Second
+
+// Handler отдает поток новых постов в формате SSE.
+// Параметры s, tag, lang, source задают фильтр, заголовок Last-Event-ID
+// (или параметр last_event_id) - последнее полученное событие.
+func Handler(b *Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		q := r.URL.Query()
+		filter := Filter{Search: q.Get("s"), Tag: q.Get("tag"), Lang: q.Get("lang"), Source: q.Get("source")}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = q.Get("last_event_id")
+		}
+		last, _ := strconv.Atoi(lastID)
+
+		sub, missed := b.Subscribe(filter, last, 0)
+		defer b.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // отключает буферизацию в nginx
+		w.WriteHeader(http.StatusOK)
+
+		// Интервал переподключения для EventSource
+		fmt.Fprint(w, "retry: 3000\n\n")
+		for _, ev := range missed {
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, ev); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, ev Event) error {
+	data, err := json.Marshal(ev.Post)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: post\ndata: %s\n\n", ev.ID, data)
+	return err
+}
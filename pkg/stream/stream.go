@@ -0,0 +1,150 @@
+// Package stream рассылает новые посты подписчикам в реальном времени
+// (Server-Sent Events) и хранит недавние события для возобновления потока.
+package stream
+
+import (
+	"strings"
+	"sync"
+
+	"news/pkg/postgres"
+)
+
+// Event - опубликованный пост. ID события совпадает с ID поста.
+type Event struct {
+	ID   int
+	Post postgres.Post
+}
+
+// Filter отбирает посты для подписчика, пустые поля не проверяются
+type Filter struct {
+	Search string // все слова запроса должны встретиться в заголовке или тексте
+	Tag    string // тег без учета регистра
+	Lang   string
+	Source string
+}
+
+// Match проверяет, подходит ли пост под фильтр
+func (f Filter) Match(p postgres.Post) bool {
+	if f.Lang != "" && p.Lang != f.Lang {
+		return false
+	}
+	if f.Source != "" && p.Source != f.Source {
+		return false
+	}
+	if f.Tag != "" {
+		found := false
+		for _, t := range p.Tags {
+			if strings.EqualFold(t.Name, f.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Search != "" {
+		text := strings.ToLower(p.Title + " " + p.Content)
+		for _, w := range strings.Fields(strings.ToLower(f.Search)) {
+			if !strings.Contains(text, w) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Subscription - подписка на поток. Канал C закрывается при отписке
+// или если подписчик не успевает читать события.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+}
+
+// Broker рассылает события подписчикам и хранит последние size событий
+type Broker struct {
+	mu     sync.Mutex
+	size   int
+	recent []Event // по возрастанию ID
+	subs   map[*Subscription]bool
+}
+
+// NewBroker создает брокер, помнящий size последних событий
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = 1000
+	}
+	return &Broker{size: size, subs: make(map[*Subscription]bool)}
+}
+
+// Publish рассылает сохраненные посты. Посты без ID (не записанные в БД) пропускаются.
+func (b *Broker) Publish(posts []postgres.Post) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range posts {
+		if p.ID == 0 {
+			continue
+		}
+		ev := Event{ID: p.ID, Post: p}
+		b.recent = append(b.recent, ev)
+		for sub := range b.subs {
+			if !sub.filter.Match(p) {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+				// Медленный подписчик отключается, клиент переподключится с Last-Event-ID
+				b.remove(sub)
+			}
+		}
+	}
+	if len(b.recent) > b.size {
+		b.recent = append([]Event(nil), b.recent[len(b.recent)-b.size:]...)
+	}
+}
+
+// Subscribe подписывает на события по фильтру. Если lastID > 0, сначала возвращаются
+// сохраненные события с большим ID, пропущенные клиентом.
+func (b *Broker) Subscribe(filter Filter, lastID int, buffer int) (*Subscription, []Event) {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var missed []Event
+	if lastID > 0 {
+		for _, ev := range b.recent {
+			if ev.ID > lastID && filter.Match(ev.Post) {
+				missed = append(missed, ev)
+			}
+		}
+	}
+	b.subs[sub] = true
+	return sub, missed
+}
+
+// Unsubscribe отменяет подписку
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscribers возвращает число активных подписчиков
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+func post(id int, title string) postgres.Post {
+	return postgres.Post{ID: id, Title: title, Lang: "ru", Tags: []postgres.Tag{{Name: "AMD", Kind: "company"}}}
+}
+
+func TestFilterMatch(t *testing.T) {
+	p := post(1, "Новый процессор Ryzen")
+	require.True(t, Filter{}.Match(p))
+	require.True(t, Filter{Search: "ryzen процессор", Tag: "amd", Lang: "ru"}.Match(p))
+	require.False(t, Filter{Search: "intel"}.Match(p))
+	require.False(t, Filter{Tag: "Intel"}.Match(p))
+	require.False(t, Filter{Lang: "en"}.Match(p))
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(2)
+	b.Publish([]postgres.Post{post(1, "a"), {Title: "не сохранен"}, post(2, "b"), post(3, "c")})
+
+	// Событие 1 вытеснено из буфера, событие без ID не публикуется
+	sub, missed := b.Subscribe(Filter{}, 1, 0)
+	defer b.Unsubscribe(sub)
+	require.Len(t, missed, 2)
+	require.Equal(t, 2, missed[0].ID)
+	require.Equal(t, 3, missed[1].ID)
+
+	b.Publish([]postgres.Post{post(4, "d")})
+	ev := <-sub.C
+	require.Equal(t, 4, ev.ID)
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(10)
+	sub, _ := b.Subscribe(Filter{}, 0, 1)
+	b.Publish([]postgres.Post{post(1, "a"), post(2, "b")})
+	require.Equal(t, 0, b.Subscribers())
+
+	<-sub.C
+	_, ok := <-sub.C
+	require.False(t, ok)
+}
+
+func TestHandler(t *testing.T) {
+	b := NewBroker(10)
+	b.Publish([]postgres.Post{post(1, "Старая"), post(2, "Пропущенная")})
+	srv := httptest.NewServer(Handler(b))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"?tag=amd", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	next := func(prefix string) string {
+		for {
+			select {
+			case l := <-lines:
+				if strings.HasPrefix(l, prefix) {
+					return l
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("no line with prefix %q", prefix)
+			}
+		}
+	}
+
+	require.Equal(t, "id: 2", next("id:"))
+	require.Contains(t, next("data:"), "Пропущенная")
+
+	require.Eventually(t, func() bool { return b.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+	b.Publish([]postgres.Post{post(3, "Свежая")})
+	require.Equal(t, "id: 3", next("id:"))
+	require.Contains(t, next("data:"), "Свежая")
+}
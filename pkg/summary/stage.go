@@ -0,0 +1,31 @@
+package summary
+
+import (
+	"encoding/json"
+
+	"news/pkg/pipeline"
+)
+
+func init() {
+	pipeline.Register("summarize", newStage)
+}
+
+// stage заполняет Post.Summary аннотацией текста поста
+type stage struct {
+	config Config
+}
+
+func newStage(params json.RawMessage) (pipeline.Stage, error) {
+	s := &stage{}
+	if err := pipeline.DecodeParams(params, &s.config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *stage) Name() string { return "summarize" }
+
+func (s *stage) Process(item *pipeline.Item) error {
+	item.Post.Summary = Summarize(pipeline.PlainText(item.Post.Content), item.Post.Lang, s.config)
+	return nil
+}
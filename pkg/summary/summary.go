@@ -0,0 +1,236 @@
+// Package summary строит экстрактивные аннотации: из текста выбираются самые
+// значимые предложения по алгоритму TextRank. Внешние сервисы не используются.
+package summary
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"news/pkg/tagger"
+)
+
+// Config ограничения аннотации
+type Config struct {
+	Sentences int `json:"sentences"` // максимум предложений
+	MaxChars  int `json:"max_chars"` // максимум символов
+}
+
+func (c Config) withDefaults() Config {
+	if c.Sentences <= 0 {
+		c.Sentences = 2
+	}
+	if c.MaxChars <= 0 {
+		c.MaxChars = 400
+	}
+	return c
+}
+
+const (
+	damping    = 0.85
+	iterations = 50
+	epsilon    = 1e-4
+)
+
+// Summarize возвращает аннотацию текста на языке lang. Короткий текст возвращается как есть.
+func Summarize(text, lang string, config Config) string {
+	config = config.withDefaults()
+	text = strings.Join(strings.Fields(text), " ")
+	sentences := Sentences(text)
+	if len(sentences) <= config.Sentences && utf8.RuneCountInString(text) <= config.MaxChars {
+		return text
+	}
+
+	scores := rank(sentences, lang)
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	// При равных весах предпочтение отдается предложениям ближе к началу
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	var chosen []int
+	length := 0
+	for _, i := range order {
+		if len(chosen) == config.Sentences {
+			break
+		}
+		n := utf8.RuneCountInString(sentences[i])
+		if length > 0 && length+n+1 > config.MaxChars {
+			continue
+		}
+		chosen = append(chosen, i)
+		length += n + 1
+	}
+	sort.Ints(chosen)
+
+	parts := make([]string, len(chosen))
+	for k, i := range chosen {
+		parts[k] = sentences[i]
+	}
+	return truncate(strings.Join(parts, " "), config.MaxChars)
+}
+
+// rank вычисляет вес предложений: граф, где ребра - сходство по общим основам слов
+func rank(sentences []string, lang string) []float64 {
+	n := len(sentences)
+	words := make([]map[string]bool, n)
+	for i, s := range sentences {
+		words[i] = make(map[string]bool)
+		for _, st := range tagger.Stems(s, lang) {
+			words[i][st] = true
+		}
+	}
+
+	weights := make([][]float64, n)
+	outSum := make([]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			w := similarity(words[i], words[j])
+			weights[i][j], weights[j][i] = w, w
+			outSum[i] += w
+			outSum[j] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	for it := 0; it < iterations; it++ {
+		next := make([]float64, n)
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 {
+					sum += weights[j][i] / outSum[j] * scores[j]
+				}
+			}
+			next[i] = (1 - damping) + damping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < epsilon {
+			break
+		}
+	}
+	return scores
+}
+
+// similarity - мера сходства предложений из оригинальной статьи о TextRank
+func similarity(a, b map[string]bool) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	return float64(common) / (math.Log(float64(len(a))) + math.Log(float64(len(b))))
+}
+
+// Сокращения, после которых точка не завершает предложение
+var abbreviations = map[string]bool{
+	"т.е": true, "т.д": true, "т.п": true, "т.к": true, "др": true, "г": true, "гг": true,
+	"см": true, "им": true, "ул": true, "стр": true, "млн": true, "млрд": true, "тыс": true,
+	"руб": true, "долл": true, "mr": true, "mrs": true, "dr": true, "vs": true, "inc": true,
+	"e.g": true, "i.e": true, "etc": true, "no": true,
+}
+
+// Sentences разбивает текст на предложения
+func Sentences(text string) []string {
+	var res []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !isTerminator(runes[i]) {
+			continue
+		}
+		// Серия знаков "?!..." завершает предложение целиком
+		end := i
+		for end+1 < len(runes) && (isTerminator(runes[end+1]) || isClosing(runes[end+1])) {
+			end++
+		}
+		if end+1 < len(runes) && !unicode.IsSpace(runes[end+1]) {
+			i = end
+			continue
+		}
+		if runes[i] == '.' && isAbbreviation(runes[start:i]) {
+			i = end
+			continue
+		}
+		if next := nextLetter(runes[end+1:]); next != 0 && unicode.IsLower(next) {
+			i = end
+			continue
+		}
+		if s := strings.TrimSpace(string(runes[start : end+1])); s != "" {
+			res = append(res, s)
+		}
+		start = end + 1
+		i = end
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		res = append(res, s)
+	}
+	return res
+}
+
+func isTerminator(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isClosing(r rune) bool {
+	return r == '"' || r == '»' || r == ')' || r == '\''
+}
+
+// isAbbreviation проверяет, является ли последнее слово перед точкой сокращением или инициалом
+func isAbbreviation(before []rune) bool {
+	i := len(before)
+	for i > 0 && !unicode.IsSpace(before[i-1]) {
+		i--
+	}
+	word := strings.ToLower(strings.TrimLeft(string(before[i:]), "(«\""))
+	if utf8.RuneCountInString(word) == 1 && unicode.IsUpper(before[len(before)-1]) {
+		return true
+	}
+	return abbreviations[word]
+}
+
+func nextLetter(rest []rune) rune {
+	for _, r := range rest {
+		if unicode.IsLetter(r) {
+			return r
+		}
+		if !unicode.IsSpace(r) && !isClosing(r) && r != '«' && r != '"' {
+			return 0
+		}
+	}
+	return 0
+}
+
+// truncate обрезает текст по границе слова и добавляет многоточие
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	cut := max - 1
+	for cut > 0 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = max - 1
+	}
+	return strings.TrimRight(string(runes[:cut]), " ,;:—-") + "…"
+}
@@ -0,0 +1,47 @@
+package summary_test
+
+import (
+	"strings"
+	"testing"
+
+	"news/pkg/summary"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentences(t *testing.T) {
+	got := summary.Sentences(`Компания А. С. Иванова выпустила чип, т.е. новый продукт. Цена - 10 тыс. руб. в рознице! Что дальше? «Посмотрим», - сказал он.`)
+	require.Equal(t, []string{
+		"Компания А. С. Иванова выпустила чип, т.е. новый продукт.",
+		"Цена - 10 тыс. руб. в рознице!",
+		"Что дальше?",
+		"«Посмотрим», - сказал он.",
+	}, got)
+}
+
+func TestSummarize(t *testing.T) {
+	text := `Компания Intel представила новый процессор для ноутбуков.
+Новый процессор Intel работает быстрее предыдущего поколения и потребляет меньше энергии.
+Погода в городе сегодня солнечная.
+Ноутбуки с новым процессором Intel появятся в продаже весной.
+Футбольный матч перенесли на воскресенье.`
+
+	got := summary.Summarize(text, "ru", summary.Config{Sentences: 2, MaxChars: 400})
+	require.NotContains(t, got, "Погода")
+	require.NotContains(t, got, "Футбольный")
+	require.Contains(t, got, "процессор")
+
+	// Порядок предложений сохраняется
+	sentences := summary.Sentences(got)
+	require.Len(t, sentences, 2)
+	require.Less(t, strings.Index(text, sentences[0]), strings.Index(text, sentences[1]))
+}
+
+func TestSummarizeShortAndLimits(t *testing.T) {
+	require.Equal(t, "Короткая новость.", summary.Summarize("  Короткая   новость. ", "ru", summary.Config{}))
+
+	long := strings.Repeat("слово ", 200)
+	got := summary.Summarize(long, "ru", summary.Config{MaxChars: 50})
+	require.LessOrEqual(t, len([]rune(got)), 50)
+	require.True(t, strings.HasSuffix(got, "…"))
+}
@@ -0,0 +1,55 @@
+package tagger
+
+import (
+	"encoding/json"
+
+	"news/pkg/pipeline"
+	"news/pkg/postgres"
+)
+
+// stage - этап конвейера, добавляющий посту теги
+type stage struct {
+	tagger *Tagger
+}
+
+// StageFactory возвращает фабрику этапа конвейера, который размечает посты тегами t.
+// Этап не имеет параметров; корпус пополняется только сохраненными постами.
+func (t *Tagger) StageFactory() pipeline.Factory {
+	return func(params json.RawMessage) (pipeline.Stage, error) {
+		// Параметров нет, но опечатки в конфигурации должны быть ошибкой
+		if err := pipeline.DecodeParams(params, &struct{}{}); err != nil {
+			return nil, err
+		}
+		return &stage{tagger: t}, nil
+	}
+}
+
+func (s *stage) Name() string { return "autotag" }
+
+func (s *stage) Process(item *pipeline.Item) error {
+	content := pipeline.PlainText(item.Post.Content)
+	for _, tag := range s.tagger.Extract(item.Post.Lang, item.Post.Title, content) {
+		item.Post.Tags = appendTag(item.Post.Tags, postgres.Tag{Name: tag.Name, Kind: tag.Kind})
+	}
+	return nil
+}
+
+// Commit добавляет в корпус новые посты. У постов, которые уже были в БД,
+// ID остается 0: они попали в корпус при старте сервиса.
+func (s *stage) Commit(posts []postgres.Post) {
+	for _, p := range posts {
+		if p.ID != 0 {
+			s.tagger.Learn(p.Lang, p.Title, pipeline.PlainText(p.Content))
+		}
+	}
+}
+
+// appendTag добавляет тег, если такого имени еще нет
+func appendTag(tags []postgres.Tag, tag postgres.Tag) []postgres.Tag {
+	for _, t := range tags {
+		if t.Name == tag.Name {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
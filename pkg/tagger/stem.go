@@ -0,0 +1,56 @@
+package tagger
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Окончания, которые отбрасывает упрощенный стеммер, от длинных к коротким
+var suffixesRU = []string{
+	"ованиями", "ованием", "ования", "ование",
+	"ениями", "ением", "ения", "ение", "ости", "ость",
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ых", "их", "ая", "яя", "ое", "ее", "ой", "ей", "ий", "ый", "ом", "ем",
+	"ам", "ям", "ах", "ях", "ов", "ев", "ия", "ие", "ию", "ии",
+	"ать", "ять", "ить", "еть", "ла", "ли", "ло",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+var suffixesEN = []string{
+	"ations", "ation", "ings", "ing", "ies", "es", "ed", "ly", "s",
+}
+
+const minStemLen = 3
+
+// stem приводит слово к упрощенной основе. Кириллические слова обрабатываются
+// русскими окончаниями, остальные - английскими.
+func stem(word string) string {
+	suffixes := suffixesEN
+	if isCyrillic(word) {
+		suffixes = suffixesRU
+	}
+	for _, suf := range suffixes {
+		if !strings.HasSuffix(word, suf) {
+			continue
+		}
+		base := word[:len(word)-len(suf)]
+		if utf8.RuneCountInString(base) < minStemLen {
+			continue
+		}
+		if suf == "ies" {
+			base += "y"
+		}
+		return base
+	}
+	return word
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package tagger
+
+import "strings"
+
+// Стоп-слова не участвуют в подсчете ключевых слов
+var stopwordsRU = words(`
+а без более бы был была были было быть в вам вас весь во вот все всего всех вы где да даже для до его ее ей ею если есть еще же за здесь и из или им их к как ко когда кто ли либо мне может мы на надо наш не него нее нет ни них но ну о об однако он она они оно от очень по под при с со так также такой там те тем то того тоже той только том ты у уже хотя чего чей чем что чтобы чье чья эта эти это я
+этот этом этого этой свой своей свои своих который которая которые которое которых котором будет будут может могут можно нужно сейчас теперь после перед между через около более менее самый сам сама само сами всё ещё уж пока лишь раз два три год года году лет также кроме
+`)
+
+var stopwordsEN = words(`
+a about above after again against all am an and any are as at be because been before being below between both but by can could did do does doing down during each few for from further had has have having he her here hers herself him himself his how i if in into is it its itself just me more most my myself no nor not now of off on once only or other our ours out over own same she should so some such than that the their theirs them themselves then there these they this those through to too under until up very was we were what when where which while who whom why will with would you your yours yourself
+new also get gets got says said one two three year years
+`)
+
+var stopwordsUK = words(`
+а але без би був була були було бути в вам вас весь від во вона вони воно все всі де до його її з за і із їх й як як які ми на над не ні но по під при про рік року році та так також те тим то тож тому ту у уже це цей ця ці через що щоб я є
+`)
+
+var stopwordsDE = words(`
+aber als am an auch auf aus bei bin bis bist da dadurch daher darum das dass dein deine dem den der des dessen dich die dies diese dieser dieses doch dort du durch ein eine einem einen einer eines er es euer eure für hatte hatten hattest hattet hier hinter ich ihr ihre im in ist ja jede jedem jeden jeder jedes jener jenes jetzt kann kannst können könnt machen mein meine mit muß mußt musst müssen müßt nach nachdem nein nicht noch nun oder seid sein seine sich sie sind soll sollen sollst sollt sonst soweit sowie und unser unsere unter vom von vor wann warum was weiter weitere wenn wer werde werden werdet weshalb wie wieder wieso wir wird wirst wo woher wohin zu zum zur über jahr jahre neue neuen
+`)
+
+// stopwords возвращает проверку стоп-слов для языка. В тексте на одном языке часто
+// встречаются слова другого (названия, цитаты), поэтому для ru и en учитываются оба
+// списка, а выбор языка определяет, какие дополнительные списки подключаются.
+func stopwords(lang string) func(string) bool {
+	switch lang {
+	case "ru", "en", "":
+		return func(w string) bool { return stopwordsRU[w] || stopwordsEN[w] }
+	case "uk":
+		return func(w string) bool { return stopwordsUK[w] || stopwordsEN[w] }
+	case "de":
+		return func(w string) bool { return stopwordsDE[w] || stopwordsEN[w] }
+	default:
+		return func(w string) bool { return stopwordsEN[w] }
+	}
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
@@ -0,0 +1,270 @@
+// Package tagger выделяет из текста новостей ключевые слова (TF-IDF по корпусу)
+// и именованные сущности по словарю. Работает без внешних сервисов.
+package tagger
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Виды тегов
+const (
+	KindKeyword = "keyword"
+	KindCompany = "company"
+	KindPerson  = "person"
+	KindPlace   = "place"
+)
+
+// Tag - тег поста
+type Tag struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// Entity - сущность словаря и ее написания в тексте
+type Entity struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// Config настройки теггера
+type Config struct {
+	Keywords   int                 `json:"keywords"`    // максимум ключевых слов на пост
+	MinDocs    int                 `json:"min_docs"`    // минимальный размер корпуса для TF-IDF
+	TitleBoost float64             `json:"title_boost"` // вес слов заголовка
+	Entities   map[string][]Entity `json:"entities"`    // вид сущности -> словарь
+}
+
+func (c Config) withDefaults() Config {
+	if c.Keywords <= 0 {
+		c.Keywords = 5
+	}
+	if c.MinDocs <= 0 {
+		c.MinDocs = 20
+	}
+	if c.TitleBoost <= 0 {
+		c.TitleBoost = 2
+	}
+	return c
+}
+
+// alias - написание сущности в нижнем регистре
+type alias struct {
+	text string
+	tag  Tag
+}
+
+// Tagger накапливает статистику документов корпуса и выделяет теги
+type Tagger struct {
+	config  Config
+	aliases []alias
+
+	mu   sync.RWMutex
+	docs int            // документов в корпусе
+	df   map[string]int // основа -> число документов, в которых она встречается
+}
+
+func New(config Config) *Tagger {
+	config = config.withDefaults()
+	t := &Tagger{
+		config: config,
+		df:     make(map[string]int),
+	}
+	for kind, entities := range config.Entities {
+		for _, e := range entities {
+			names := append([]string{e.Name}, e.Aliases...)
+			for _, n := range names {
+				n = strings.ToLower(strings.TrimSpace(n))
+				if n != "" {
+					t.aliases = append(t.aliases, alias{text: n, tag: Tag{Name: e.Name, Kind: kind}})
+				}
+			}
+		}
+	}
+	// Длинные написания проверяются первыми: "Samsung Electronics" раньше "Samsung"
+	sort.Slice(t.aliases, func(i, j int) bool { return len(t.aliases[i].text) > len(t.aliases[j].text) })
+	return t
+}
+
+// Learn добавляет документ на языке lang в корпус
+func (t *Tagger) Learn(lang, title, content string) {
+	seen := make(map[string]bool)
+	for _, tok := range tokenize(title+" "+content, lang) {
+		seen[tok.stem] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.docs++
+	for s := range seen {
+		t.df[s]++
+	}
+}
+
+// Extract возвращает сущности и ключевые слова документа на языке lang, не меняя корпус.
+// Пустой lang означает, что язык неизвестен.
+func (t *Tagger) Extract(lang, title, content string) []Tag {
+	tags := t.entities(title + " " + content)
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		seen[strings.ToLower(tag.Name)] = true
+	}
+	for _, kw := range t.keywords(lang, title, content) {
+		if !seen[kw] {
+			tags = append(tags, Tag{Name: kw, Kind: KindKeyword})
+			seen[kw] = true
+		}
+	}
+	return tags
+}
+
+// keywords выбирает слова с наибольшим TF-IDF
+func (t *Tagger) keywords(lang, title, content string) []string {
+	type stat struct {
+		weight  float64
+		surface map[string]int // написания основы в документе
+	}
+	stats := make(map[string]*stat)
+	total := 0.0
+	add := func(text string, weight float64) {
+		for _, tok := range tokenize(text, lang) {
+			st, ok := stats[tok.stem]
+			if !ok {
+				st = &stat{surface: make(map[string]int)}
+				stats[tok.stem] = st
+			}
+			st.weight += weight
+			st.surface[tok.word]++
+			total += weight
+		}
+	}
+	add(title, t.config.TitleBoost)
+	add(content, 1)
+	if total == 0 {
+		return nil
+	}
+
+	t.mu.RLock()
+	docs, useIDF := t.docs, t.docs >= t.config.MinDocs
+	type scored struct {
+		stem  string
+		score float64
+	}
+	var list []scored
+	for s, st := range stats {
+		score := st.weight / total
+		if useIDF {
+			score *= math.Log(float64(docs+1)/float64(t.df[s]+1)) + 1
+		}
+		list = append(list, scored{s, score})
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		return list[i].stem < list[j].stem
+	})
+	if len(list) > t.config.Keywords {
+		list = list[:t.config.Keywords]
+	}
+
+	res := make([]string, 0, len(list))
+	for _, sc := range list {
+		res = append(res, mostFrequent(stats[sc.stem].surface))
+	}
+	return res
+}
+
+// entities находит сущности словаря, учитывая границы слов
+func (t *Tagger) entities(text string) []Tag {
+	lower := strings.ToLower(text)
+	var tags []Tag
+	found := make(map[Tag]bool)
+	for _, a := range t.aliases {
+		if found[a.tag] || !containsWord(lower, a.text) {
+			continue
+		}
+		found[a.tag] = true
+		tags = append(tags, a.tag)
+	}
+	return tags
+}
+
+// containsWord ищет phrase в text так, чтобы вокруг не было букв и цифр
+func containsWord(text, phrase string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func mostFrequent(counts map[string]int) string {
+	best, n := "", -1
+	for w, c := range counts {
+		if c > n || (c == n && w < best) {
+			best, n = w, c
+		}
+	}
+	return best
+}
+
+// Stems возвращает основы значимых слов текста на языке lang
+func Stems(text, lang string) []string {
+	tokens := tokenize(text, lang)
+	stems := make([]string, len(tokens))
+	for i, tok := range tokens {
+		stems[i] = tok.stem
+	}
+	return stems
+}
+
+type token struct {
+	word string
+	stem string
+}
+
+// tokenize разбивает текст на слова без стоп-слов языка lang и чисел
+func tokenize(text, lang string) []token {
+	stop := stopwords(lang)
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	var tokens []token
+	for _, w := range fields {
+		w = strings.Trim(w, "-")
+		if utf8.RuneCountInString(w) < minStemLen || stop(w) || isNumber(w) {
+			continue
+		}
+		tokens = append(tokens, token{word: w, stem: stem(w)})
+	}
+	return tokens
+}
+
+func isNumber(w string) bool {
+	for _, r := range w {
+		if !unicode.IsDigit(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
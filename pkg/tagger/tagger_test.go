@@ -0,0 +1,69 @@
+package tagger
+
+import (
+	"encoding/json"
+	"testing"
+
+	"news/pkg/pipeline"
+	"news/pkg/postgres"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStem(t *testing.T) {
+	require.Equal(t, stem("процессоры"), stem("процессоров"))
+	require.Equal(t, stem("видеокарта"), stem("видеокарты"))
+	require.Equal(t, "process", stem("processing"))
+	require.Equal(t, "battery", stem("batteries"))
+	require.Equal(t, "чип", stem("чип")) // короткие слова не обрезаются
+}
+
+func TestExtractEntities(t *testing.T) {
+	tg := New(Config{Entities: map[string][]Entity{
+		KindCompany: {{Name: "Intel", Aliases: []string{"Интел"}}, {Name: "AMD"}},
+		KindPlace:   {{Name: "Китай", Aliases: []string{"Китае"}}},
+	}})
+
+	tags := tg.Extract("ru", "Интел представила чипы", "Производство чипов в Китае. Amdahl law не сущность.")
+	require.Contains(t, tags, Tag{Name: "Intel", Kind: KindCompany})
+	require.Contains(t, tags, Tag{Name: "Китай", Kind: KindPlace})
+	require.NotContains(t, tags, Tag{Name: "AMD", Kind: KindCompany})
+}
+
+func TestExtractKeywords(t *testing.T) {
+	tg := New(Config{Keywords: 2, MinDocs: 3})
+
+	// Слово "новости" встречается во всех документах корпуса и теряет вес
+	tg.Learn("ru", "Новости технологий", "новости рынка")
+	tg.Learn("ru", "Новости спорта", "новости футбола")
+	tg.Learn("ru", "Новости политики", "новости выборов")
+
+	tags := tg.Extract("ru", "Новости: процессоры дешевеют", "Процессоры и процессор подешевели, новости")
+	require.Len(t, tags, 2)
+	require.Equal(t, Tag{Name: "процессоры", Kind: KindKeyword}, tags[0])
+	for _, tag := range tags {
+		require.NotEqual(t, "новости", tag.Name)
+	}
+}
+
+func TestTokenizeStopwords(t *testing.T) {
+	for _, tok := range tokenize("Это был the самый 2024 быстрый year", "ru") {
+		require.NotContains(t, []string{"это", "был", "the", "самый", "2024", "year"}, tok.word)
+	}
+}
+
+func TestStageCommit(t *testing.T) {
+	tg := New(Config{})
+	stage, err := tg.StageFactory()(nil)
+	require.NoError(t, err)
+	_, err = tg.StageFactory()(json.RawMessage(`{"keywrods": 3}`))
+	require.Error(t, err)
+
+	item := pipeline.Item{Post: postgres.Post{Title: "Процессоры", Content: "Новые процессоры", Lang: "ru"}}
+	require.NoError(t, stage.Process(&item))
+	require.Zero(t, tg.docs) // до записи в БД корпус не меняется
+
+	// Пост, который уже был в БД (ID = 0), повторно не учитывается
+	stage.(pipeline.Committer).Commit([]postgres.Post{{ID: 1, Title: "Процессоры"}, {Title: "Старый пост"}})
+	require.Equal(t, 1, tg.docs)
+}
@@ -0,0 +1,157 @@
+// Package tracing - распределенная трассировка OpenTelemetry. Контекст трассы
+// передается между сервисами заголовками W3C Trace Context (traceparent, tracestate).
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры спанов
+const (
+	ExporterNone = "none" // спаны не записываются, контекст трассы все равно передается дальше
+	ExporterOTLP = "otlp" // OTLP/HTTP, например в OpenTelemetry Collector или Jaeger
+	ExporterFile = "file" // JSON в файл, для локальной отладки
+)
+
+// Переменные окружения. Адрес OTLP задается стандартными переменными
+// OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT.
+const (
+	EnvExporter = "OTEL_TRACES_EXPORTER"
+	EnvFile     = "OTEL_TRACES_FILE"
+	EnvRatio    = "OTEL_TRACES_SAMPLER_ARG"
+)
+
+// Config настройки трассировки
+type Config struct {
+	Exporter    string  `json:"exporter"`     // none, otlp или file
+	Endpoint    string  `json:"endpoint"`     // адрес OTLP коллектора host:port; пусто - из окружения
+	Insecure    bool    `json:"insecure"`     // OTLP без TLS
+	File        string  `json:"file"`         // файл для экспортера file
+	SampleRatio float64 `json:"sample_ratio"` // доля записываемых трасс без родителя; 0 - все
+}
+
+// ConfigFromEnv читает настройки из переменных окружения
+func ConfigFromEnv() Config {
+	cfg := Config{Exporter: os.Getenv(EnvExporter), File: os.Getenv(EnvFile)}
+	if v, err := strconv.ParseFloat(os.Getenv(EnvRatio), 64); err == nil {
+		cfg.SampleRatio = v
+	}
+	return cfg
+}
+
+// Setup настраивает глобальный провайдер трассировки сервиса и возвращает функцию,
+// которая отправляет накопленные спаны при остановке.
+func Setup(service string, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	closeFile := func() error { return nil }
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: otlp exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, errors.New("tracing: file is required for the file exporter")
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("tracing: file exporter: %w", err)
+		}
+		exporter, closeFile = exp, f.Close
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+
+	tp := NewProvider(service, sdktrace.NewBatchSpanProcessor(exporter), cfg.SampleRatio)
+	otel.SetTracerProvider(tp)
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), closeFile())
+	}, nil
+}
+
+// NewProvider создает провайдер с ресурсом service.name. Решение о записи
+// дочерних спанов принимает родитель, корневые записываются с долей ratio.
+func NewProvider(service string, processor sdktrace.SpanProcessor, ratio float64) *sdktrace.TracerProvider {
+	sampler := sdktrace.AlwaysSample()
+	if ratio > 0 && ratio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(ratio)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+}
+
+// Middleware создает серверный спан на каждый запрос. Родительский контекст берется
+// из заголовка traceparent. Имя спана - метод и шаблон маршрута из route.
+func Middleware(route func(path string) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http.server",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				name := route(r.URL.Path)
+				if name == "" {
+					name = "other"
+				}
+				return r.Method + " " + name
+			}))
+	}
+}
+
+// Transport создает клиентский спан на каждый исходящий запрос и добавляет
+// заголовок traceparent. Контекст берется из запроса (http.NewRequestWithContext).
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// Start начинает внутренний спан
+func Start(ctx context.Context, tracer, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracer).Start(ctx, name, opts...)
+}
+
+// End завершает спан, отмечая ошибку
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func route(path string) string {
+	if path == "/news/1" {
+		return "/news/{id}"
+	}
+	return ""
+}
+
+// memoryProvider подменяет глобальный провайдер на запись спанов в память
+func memoryProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(NewProvider("test", sdktrace.NewSimpleSpanProcessor(exp), 0))
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+
+	_, err := Setup("test", Config{Exporter: ExporterNone}) // только пропагатор
+	require.NoError(t, err)
+	return exp
+}
+
+func byName(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	m := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		m[s.Name] = s
+	}
+	return m
+}
+
+func TestPropagation(t *testing.T) {
+	exp := memoryProvider(t)
+
+	backend := httptest.NewServer(Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "test", "postgres.PostByID")
+		End(span, nil)
+		io.WriteString(w, "ok")
+	})))
+	defer backend.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	gateway := httptest.NewServer(Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL+"/news/1", nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})))
+	defer gateway.Close()
+
+	// Клиент передает свою трассу в заголовке traceparent
+	const traceID = "4bf92f3577b34da6a3ce929b0e0e4736"
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/news/detail", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	spans := byName(exp.GetSpans())
+	require.Len(t, spans, 4)
+	for _, s := range spans {
+		require.Equal(t, traceID, s.SpanContext.TraceID().String(), s.Name)
+	}
+
+	gw := spans["GET other"]
+	out := spans["HTTP GET"]
+	in := spans["GET /news/{id}"]
+	db := spans["postgres.PostByID"]
+	require.Equal(t, trace.SpanKindServer, gw.SpanKind)
+	require.Equal(t, "00f067aa0ba902b7", gw.Parent.SpanID().String())
+	require.Equal(t, trace.SpanKindClient, out.SpanKind)
+	require.Equal(t, gw.SpanContext.SpanID(), out.Parent.SpanID())
+	require.Equal(t, out.SpanContext.SpanID(), in.Parent.SpanID())
+	require.Equal(t, in.SpanContext.SpanID(), db.Parent.SpanID())
+}
+
+func TestFileExporter(t *testing.T) {
+	old := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup("test", Config{Exporter: ExporterFile, File: file})
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "test", "retention.RunOnce")
+	End(span, io.ErrUnexpectedEOF)
+	require.NoError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"Name":"retention.RunOnce"`)
+	require.Contains(t, string(b), `"Description":"unexpected EOF"`)
+}
+
+func TestSetupErrors(t *testing.T) {
+	_, err := Setup("test", Config{Exporter: "zipkin"})
+	require.Error(t, err)
+	_, err = Setup("test", Config{Exporter: ExporterFile})
+	require.Error(t, err)
+}